@@ -0,0 +1,359 @@
+package swarm
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+)
+
+// A BootstrapSeed is a bootstrap identity.MultiAddress with a priority.
+// BootstrapSeeds with a higher priority are used before BootstrapSeeds with a
+// lower priority.
+type BootstrapSeed struct {
+	MultiAddress identity.MultiAddress
+	Priority     int
+}
+
+// A PeerSource supplies the identity.MultiAddresses of candidate peers that a
+// Node can bootstrap from. This allows a Node to discover the network without
+// a fixed list of bootstrap identity.MultiAddresses, for example by using
+// peer exchange on a local network.
+type PeerSource interface {
+	Peers(ctx context.Context) (identity.MultiAddresses, error)
+}
+
+// StaticPeerSource is a PeerSource that always supplies the same
+// identity.MultiAddresses. It is used for the BootstrapMultiAddresses option
+// when the PeerSource option is nil.
+type StaticPeerSource identity.MultiAddresses
+
+// Peers returns the identity.MultiAddresses of the StaticPeerSource.
+func (source StaticPeerSource) Peers(ctx context.Context) (identity.MultiAddresses, error) {
+	return append(identity.MultiAddresses{}, source...), nil
+}
+
+// IsBootstrapped returns true if a call to Bootstrap has succeeded, otherwise
+// false. Bootstrap succeeds when at least one bootstrap Node responds, and
+// the peers that it returns are added to the dht.DHT.
+func (node *Node) IsBootstrapped() bool {
+	node.stateMu.RLock()
+	defer node.stateMu.RUnlock()
+	return node.bootstrapped
+}
+
+// bootstrapTiers groups the identity.MultiAddresses supplied by the PeerSource
+// option, and the BootstrapSeeds option, by priority, from the highest
+// priority to the lowest. identity.MultiAddresses from the PeerSource have a
+// priority of zero. The identity.MultiAddress of the Node is removed. A Node
+// cannot bootstrap using itself, but it is common for all Nodes in a
+// deployment to share a list of bootstrap identity.MultiAddresses.
+func (node *Node) bootstrapTiers() []identity.MultiAddresses {
+	options := node.options()
+	source := options.PeerSource
+	if source == nil {
+		source = StaticPeerSource(options.BootstrapMultiAddresses)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if options.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), options.Timeout)
+	}
+	defer cancel()
+	bootstrapMultiAddresses, err := source.Peers(ctx)
+	if err != nil && options.Debug >= DebugLow {
+		log.Println(err)
+	}
+
+	seeds := make([]BootstrapSeed, 0, len(bootstrapMultiAddresses)+len(options.BootstrapSeeds))
+	for _, bootstrapMultiAddress := range bootstrapMultiAddresses {
+		seeds = append(seeds, BootstrapSeed{MultiAddress: bootstrapMultiAddress})
+	}
+	seeds = append(seeds, options.BootstrapSeeds...)
+	sort.SliceStable(seeds, func(i, j int) bool {
+		return seeds[i].Priority > seeds[j].Priority
+	})
+
+	tiers := []identity.MultiAddresses{}
+	for i, seed := range seeds {
+		if seed.MultiAddress.Address() == node.Address() {
+			if options.Debug >= DebugLow {
+				log.Printf("%v is ignoring itself as a bootstrap node\n", node.Address())
+			}
+			continue
+		}
+		if len(tiers) == 0 || (i > 0 && seeds[i-1].Priority != seed.Priority) {
+			tiers = append(tiers, identity.MultiAddresses{})
+		}
+		tiers[len(tiers)-1] = append(tiers[len(tiers)-1], seed.MultiAddress)
+	}
+	return tiers
+}
+
+// bootstrapSequentially bootstraps using each bootstrap Node, one at a time.
+// The peers returned by all bootstrap Nodes are merged and added to the
+// dht.DHT once. Lower priority tiers are only used if no bootstrap Node in the
+// higher priority tiers could be queried. Returns true if a bootstrap Node was
+// queried, otherwise false.
+func (node *Node) bootstrapSequentially(tiers []identity.MultiAddresses, results *seedResults) bool {
+	discovered := map[identity.Address]identity.MultiAddress{}
+	defer node.insertDiscoveredPeers(discovered)
+
+	for _, tier := range tiers {
+		succeeded := false
+		for _, bootstrapMultiAddress := range tier {
+			peers, err := node.queryBootstrapMultiAddress(bootstrapMultiAddress, node.Address())
+			results.record(bootstrapMultiAddress, err)
+			if err != nil {
+				continue
+			}
+			succeeded = true
+			mergeDiscoveredPeers(discovered, peers)
+		}
+		if succeeded {
+			return true
+		}
+	}
+	return false
+}
+
+// bootstrapConcurrently bootstraps using all bootstrap Nodes in a tier at the
+// same time, using at most BootstrapWorkers goroutines. The peers returned by
+// all bootstrap Nodes are merged and added to the dht.DHT once. Each lower
+// priority tier is started after the BootstrapStagger option, or after the
+// higher priority tier has finished, unless a bootstrap Node has already been
+// queried. Returns true if a bootstrap Node was queried, otherwise false.
+func (node *Node) bootstrapConcurrently(tiers []identity.MultiAddresses, results *seedResults) bool {
+	options := node.options()
+	mu := new(sync.Mutex)
+	succeeded := false
+	discovered := map[identity.Address]identity.MultiAddress{}
+	wg := new(sync.WaitGroup)
+	workers := node.bootstrapWorkers()
+
+	for i, tier := range tiers {
+		if i > 0 {
+			mu.Lock()
+			done := succeeded
+			mu.Unlock()
+			if done {
+				break
+			}
+		}
+
+		tierWg := new(sync.WaitGroup)
+		tierWg.Add(len(tier))
+		wg.Add(len(tier))
+		for _, bootstrapMultiAddress := range tier {
+			workers <- struct{}{}
+			go func(bootstrapMultiAddress identity.MultiAddress) {
+				defer wg.Done()
+				defer tierWg.Done()
+				defer func() { <-workers }()
+				defer node.recoverBootstrapWorker()
+				peers, err := node.queryBootstrapMultiAddress(bootstrapMultiAddress, node.Address())
+				results.record(bootstrapMultiAddress, err)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				succeeded = true
+				mergeDiscoveredPeers(discovered, peers)
+				mu.Unlock()
+			}(bootstrapMultiAddress)
+		}
+
+		// Wait for the stagger, or for the tier to finish, before starting the
+		// next tier.
+		if i < len(tiers)-1 && options.BootstrapStagger > 0 {
+			tierDone := make(chan struct{})
+			go func() {
+				tierWg.Wait()
+				close(tierDone)
+			}()
+			timer := time.NewTimer(options.BootstrapStagger)
+			select {
+			case <-timer.C:
+			case <-tierDone:
+			}
+			timer.Stop()
+		}
+	}
+	wg.Wait()
+	node.insertDiscoveredPeers(discovered)
+	return succeeded
+}
+
+// bootstrapWorkers returns a semaphore that limits the number of goroutines
+// used to bootstrap concurrently to the BootstrapWorkers option.
+func (node *Node) bootstrapWorkers() chan struct{} {
+	workers := node.options().BootstrapWorkers
+	if workers < 1 {
+		workers = DefaultBootstrapWorkers
+	}
+	return make(chan struct{}, workers)
+}
+
+// recoverBootstrapWorker recovers from a panic in a goroutine that is
+// bootstrapping, so that a single bootstrap Node cannot crash the Node. It
+// must be deferred.
+func (node *Node) recoverBootstrapWorker() {
+	if r := recover(); r != nil && node.options().Debug >= DebugLow {
+		log.Printf("%v recovered from a panic while bootstrapping: %v\n", node.Address(), r)
+	}
+}
+
+// mergeDiscoveredPeers adds peers returned by a bootstrap Node to the
+// discovered peers. A peer that was returned by more than one bootstrap Node
+// is only kept once.
+func mergeDiscoveredPeers(discovered map[identity.Address]identity.MultiAddress, peers identity.MultiAddresses) {
+	for _, peer := range peers {
+		discovered[peer.Address()] = peer
+	}
+}
+
+// insertDiscoveredPeers adds the distinct peers discovered by bootstrapping
+// to the dht.DHT in a single batch.
+func (node *Node) insertDiscoveredPeers(discovered map[identity.Address]identity.MultiAddress) {
+	peers := make(identity.MultiAddresses, 0, len(discovered))
+	for _, peer := range discovered {
+		peers = append(peers, peer)
+	}
+	if node.options().Debug >= DebugMedium {
+		log.Printf("%v discovered %v distinct peers\n", node.Address(), len(peers))
+	}
+	node.insertBootstrapPeers(peers)
+}
+
+// bootstrapRandomTargets looks up a random identity.Address in each bucket
+// that is further away from the Node than its closest peer. Buckets closer
+// than the closest peer are populated by looking up the Node itself.
+func (node *Node) bootstrapRandomTargets(bootstrapMultiAddresses identity.MultiAddresses) {
+	options := node.options()
+	if len(bootstrapMultiAddresses) == 0 {
+		return
+	}
+
+	closest := 0
+	for _, peer := range node.DHT.MultiAddresses() {
+		prefix, err := node.Address().SamePrefixLength(peer.Address())
+		if err != nil {
+			continue
+		}
+		if prefix > closest {
+			closest = prefix
+		}
+	}
+	targets := make([]identity.Address, closest)
+	for prefix := range targets {
+		targets[prefix] = node.RandomAddress(prefix)
+	}
+	if options.Debug >= DebugMedium {
+		log.Printf("%v is looking up %v random targets...\n", node.Address(), len(targets))
+	}
+
+	// Spread the lookups across the bootstrap Nodes.
+	if options.Concurrent {
+		workers := node.bootstrapWorkers()
+		wg := new(sync.WaitGroup)
+		wg.Add(len(targets))
+		for i := range targets {
+			workers <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-workers }()
+				defer node.recoverBootstrapWorker()
+				node.bootstrapUsingMultiAddress(bootstrapMultiAddresses[i%len(bootstrapMultiAddresses)], targets[i])
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i, target := range targets {
+			node.bootstrapUsingMultiAddress(bootstrapMultiAddresses[i%len(bootstrapMultiAddresses)], target)
+		}
+	}
+}
+
+// RandomAddress returns a random identity.Address that shares exactly the
+// given number of prefix bits with the Node. The RandomSource option is used
+// to generate the identity.Address, so that it is reproducible.
+func (node *Node) RandomAddress(prefix int) identity.Address {
+	address := node.Address()
+	id := address.ID()
+	offset := len(id) - identity.IDLength + prefix/8
+	if prefix < 0 || offset >= len(id) {
+		return address
+	}
+	randomID := make(identity.ID, len(id))
+	copy(randomID, id)
+	node.randomMu.Lock()
+	node.random.Read(randomID[offset:])
+	node.randomMu.Unlock()
+
+	// Keep the prefix bits, and flip the first bit after the prefix.
+	bit := uint(7 - prefix%8)
+	mask := byte(0xFF) << (bit + 1)
+	randomID[offset] = (id[offset] & mask) | (^id[offset] & (1 << bit)) | (randomID[offset] &^ (mask | 1<<bit))
+	return randomID.Address()
+}
+
+// bootstrapCoverage looks up random identity.Addresses in empty buckets until
+// at least MinBucketsCovered buckets have a peer. Each round looks up as many
+// buckets as are still missing, starting with the furthest buckets, which are
+// the most likely to have peers, and each bucket is only looked up once.
+// Returns ErrInsufficientCoverage if every bucket has been looked up, or the
+// BucketCoverageTimeout has passed, without covering enough buckets.
+func (node *Node) bootstrapCoverage() error {
+	options := node.options()
+	timeout := options.BucketCoverageTimeout
+	if timeout <= 0 {
+		timeout = DefaultBucketCoverageTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tried := make([]bool, identity.IDLength*8)
+	for {
+		bucketLengths := node.bucketLengths(node.DHT.MultiAddresses())
+		covered := 0
+		for _, length := range bucketLengths {
+			if length > 0 {
+				covered++
+			}
+		}
+		missing := options.MinBucketsCovered - covered
+		if missing <= 0 {
+			return nil
+		}
+
+		targets := make([]identity.Address, 0, missing)
+		for prefix := range bucketLengths {
+			if len(targets) == missing {
+				break
+			}
+			if bucketLengths[prefix] > 0 || tried[prefix] {
+				continue
+			}
+			tried[prefix] = true
+			targets = append(targets, node.RandomAddress(prefix))
+		}
+		if len(targets) == 0 {
+			return ErrInsufficientCoverage
+		}
+		if options.Debug >= DebugMedium {
+			log.Printf("%v covers %v buckets, looking up %v more...\n", node.Address(), covered, len(targets))
+		}
+		for _, target := range targets {
+			peers, err := node.Lookup(ctx, target, node.Alpha())
+			if ctx.Err() != nil {
+				return ErrInsufficientCoverage
+			}
+			if err != nil && options.Debug >= DebugLow {
+				log.Println(err)
+			}
+			node.insertBootstrapPeers(peers)
+		}
+	}
+}
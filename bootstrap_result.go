@@ -0,0 +1,121 @@
+package swarm
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Constants for the categories of errors returned by bootstrap Nodes.
+const (
+	SeedErrorTimeout           = "timeout"
+	SeedErrorConnectionRefused = "connection-refused"
+	SeedErrorUnavailable       = "unavailable"
+	SeedErrorInvalidAddress    = "invalid-address"
+	SeedErrorResourceExhausted = "resource-exhausted"
+	SeedErrorProtocol          = "protocol"
+	SeedErrorUnknown           = "unknown"
+)
+
+// A SeedResult is the outcome of querying a bootstrap Node. The Category is
+// empty when the Err is nil.
+type SeedResult struct {
+	MultiAddress identity.MultiAddress
+	Err          error
+	Category     string
+}
+
+// A BootstrapResult records the outcome of querying each bootstrap Node
+// during a call to Bootstrap. Bootstrap Nodes in lower priority tiers that
+// were not needed are not included.
+type BootstrapResult struct {
+	Started  time.Time
+	Finished time.Time
+	Seeds    map[identity.Address]SeedResult
+}
+
+// Failed returns the SeedResults of the bootstrap Nodes that could not be
+// queried.
+func (result BootstrapResult) Failed() []SeedResult {
+	failed := []SeedResult{}
+	for _, seed := range result.Seeds {
+		if seed.Err != nil {
+			failed = append(failed, seed)
+		}
+	}
+	return failed
+}
+
+// LastBootstrapResult returns the BootstrapResult of the last call to
+// Bootstrap that finished. Returns false if Bootstrap has not finished.
+func (node *Node) LastBootstrapResult() (BootstrapResult, bool) {
+	node.stateMu.RLock()
+	defer node.stateMu.RUnlock()
+	if node.lastBootstrap == nil {
+		return BootstrapResult{}, false
+	}
+	result := *node.lastBootstrap
+	result.Seeds = make(map[identity.Address]SeedResult, len(node.lastBootstrap.Seeds))
+	for address, seed := range node.lastBootstrap.Seeds {
+		result.Seeds[address] = seed
+	}
+	return result, true
+}
+
+// seedResults collects SeedResults from concurrent queries to bootstrap Nodes.
+type seedResults struct {
+	mu    *sync.Mutex
+	seeds map[identity.Address]SeedResult
+}
+
+func newSeedResults() *seedResults {
+	return &seedResults{
+		mu:    new(sync.Mutex),
+		seeds: map[identity.Address]SeedResult{},
+	}
+}
+
+func (results *seedResults) record(multiAddress identity.MultiAddress, err error) {
+	results.mu.Lock()
+	defer results.mu.Unlock()
+	results.seeds[multiAddress.Address()] = SeedResult{
+		MultiAddress: multiAddress,
+		Err:          err,
+		Category:     classifySeedError(err),
+	}
+}
+
+// classifySeedError returns the category of an error returned by a bootstrap
+// Node, or an empty string if the error is nil.
+func classifySeedError(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case err == context.DeadlineExceeded:
+		return SeedErrorTimeout
+	case err == ErrInvalidMultiAddress:
+		return SeedErrorInvalidAddress
+	case err == ErrResourceExhausted:
+		return SeedErrorResourceExhausted
+	case strings.Contains(err.Error(), "connection refused"):
+		return SeedErrorConnectionRefused
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.DeadlineExceeded:
+			return SeedErrorTimeout
+		case codes.Unavailable:
+			return SeedErrorUnavailable
+		case codes.Unknown:
+			return SeedErrorUnknown
+		default:
+			return SeedErrorProtocol
+		}
+	}
+	return SeedErrorUnknown
+}
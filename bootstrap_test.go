@@ -0,0 +1,290 @@
+package swarm_test
+
+import (
+	"math/rand"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-swarm-network"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var _ = Describe("Bootstrap targets", func() {
+
+	Context("when generating random addresses", func() {
+
+		It("should generate the same addresses from the same random source", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+
+			newNode := func() *swarm.Node {
+				return swarm.NewNode(grpc.NewServer(), newMockDelegate(), swarm.Options{
+					MultiAddress: nodes[0].MultiAddress(),
+					RandomSource: rand.NewSource(42),
+				})
+			}
+			left, right := newNode(), newNode()
+			for prefix := 0; prefix < 16; prefix++ {
+				Ω(left.RandomAddress(prefix)).Should(Equal(right.RandomAddress(prefix)))
+				Ω(left.RandomAddress(prefix)).ShouldNot(Equal(left.Address()))
+			}
+		})
+	})
+
+	Context("when creating a node", func() {
+
+		It("should panic when the address has the wrong length", func() {
+			Ω(swarm.Options{}.Validate()).Should(HaveOccurred())
+			Ω(func() {
+				swarm.NewNode(grpc.NewServer(), newMockDelegate(), swarm.Options{})
+			}).Should(Panic())
+		})
+
+		It("should return an error when the address has the wrong length", func() {
+			node, err := swarm.NewNodeWithError(grpc.NewServer(), newMockDelegate(), swarm.Options{})
+			Ω(err).Should(HaveOccurred())
+			Ω(node).Should(BeNil())
+		})
+	})
+
+	Context("when reconfiguring a node", func() {
+
+		It("should change mutable options and reject immutable options", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(nodes[0].SetOptions(func(options *swarm.Options) {
+				options.Alpha = 5
+			})).ShouldNot(HaveOccurred())
+			Ω(nodes[0].Alpha()).Should(Equal(5))
+			Ω(nodes[0].SetOptions(func(options *swarm.Options) {
+				options.MultiAddress = nodes[1].MultiAddress()
+			})).Should(Equal(swarm.ErrImmutableOption))
+			Ω(nodes[0].Address()).ShouldNot(Equal(nodes[1].Address()))
+		})
+	})
+
+	Context("when using an adaptive alpha", func() {
+
+		It("should query at least one peer when it has no peers", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.AdaptiveAlpha = true
+			Ω(nodes[0].Alpha()).Should(Equal(1))
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].Alpha()).Should(Equal(1))
+		})
+	})
+
+	Context("when pausing maintenance", func() {
+
+		It("should pause and resume background maintenance", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(nodes[0].MaintenancePaused()).Should(BeFalse())
+			nodes[0].PauseMaintenance()
+			Ω(nodes[0].MaintenancePaused()).Should(BeTrue())
+			nodes[0].ResumeMaintenance()
+			Ω(nodes[0].MaintenancePaused()).Should(BeFalse())
+		})
+	})
+
+	Context("when bootstrapping without bootstrap nodes", func() {
+
+		It("should not be bootstrapped", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(nodes[0].IsBootstrapped()).Should(BeFalse())
+			Ω(nodes[0].Bootstrap()).ShouldNot(HaveOccurred())
+			Ω(nodes[0].IsBootstrapped()).Should(BeFalse())
+		})
+
+		It("should record an empty bootstrap result", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			_, ok := nodes[0].LastBootstrapResult()
+			Ω(ok).Should(BeFalse())
+			Ω(nodes[0].Bootstrap()).ShouldNot(HaveOccurred())
+			result, ok := nodes[0].LastBootstrapResult()
+			Ω(ok).Should(BeTrue())
+			Ω(result.Seeds).Should(BeEmpty())
+			Ω(result.Failed()).Should(BeEmpty())
+		})
+	})
+
+	Context("when it has lost all of its peers", func() {
+
+		newWatchedNode := func() *swarm.Node {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			return swarm.NewNode(grpc.NewServer(), newMockDelegate(), swarm.Options{
+				MultiAddress:    nodes[0].MultiAddress(),
+				AutoReBootstrap: 50 * time.Millisecond,
+			})
+		}
+		bootstrapped := func(node *swarm.Node) func() bool {
+			return func() bool {
+				_, ok := node.LastBootstrapResult()
+				return ok
+			}
+		}
+
+		It("should bootstrap again", func() {
+			node := newWatchedNode()
+			defer node.Close()
+			Eventually(bootstrapped(node), time.Second).Should(BeTrue())
+		})
+
+		It("should not bootstrap again while maintenance is paused", func() {
+			node := newWatchedNode()
+			defer node.Close()
+			node.PauseMaintenance()
+			Consistently(bootstrapped(node), 300*time.Millisecond).Should(BeFalse())
+			node.ResumeMaintenance()
+			Eventually(bootstrapped(node), time.Second).Should(BeTrue())
+		})
+
+		It("should bootstrap again once the option is set", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node := nodes[0]
+			defer node.Close()
+			Consistently(bootstrapped(node), 300*time.Millisecond).Should(BeFalse())
+			Ω(node.SetOptions(func(options *swarm.Options) {
+				options.AutoReBootstrap = 50 * time.Millisecond
+			})).ShouldNot(HaveOccurred())
+			Eventually(bootstrapped(node), time.Second).Should(BeTrue())
+		})
+	})
+
+	Context("when bootstrapping from seeds with priorities", func() {
+
+		It("should not use lower priority seeds when a higher priority seed answers", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node, high, low := nodes[0], nodes[1], nodes[2]
+			node.Options.BootstrapSeeds = []swarm.BootstrapSeed{
+				{MultiAddress: low.MultiAddress(), Priority: 0},
+				{MultiAddress: high.MultiAddress(), Priority: 1},
+			}
+			stop, err := ServeNodes([]*swarm.Node{high})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			Ω(node.Bootstrap()).ShouldNot(HaveOccurred())
+			result, ok := node.LastBootstrapResult()
+			Ω(ok).Should(BeTrue())
+			Ω(result.Seeds).Should(HaveKey(high.Address()))
+			Ω(result.Seeds).ShouldNot(HaveKey(low.Address()))
+		})
+
+		It("should start lower priority seeds after the stagger", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node, high, low := nodes[0], nodes[1], nodes[2]
+			node.Options.Concurrent = true
+			node.Options.Timeout = 2 * time.Second
+			node.Options.BootstrapStagger = 200 * time.Millisecond
+			node.Options.BootstrapSeeds = []swarm.BootstrapSeed{
+				{MultiAddress: low.MultiAddress(), Priority: 0},
+				{MultiAddress: high.MultiAddress(), Priority: 1},
+			}
+			delegate := newMockDelegate()
+			low.Delegate = delegate
+			silent, err := ListenSilently(high)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer silent.Close()
+			stop, err := ServeNodes([]*swarm.Node{low})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			// The higher priority seed never answers, so the lower priority
+			// seed is queried long before the higher priority seed times out.
+			done := make(chan error, 1)
+			go func() {
+				done <- node.Bootstrap()
+			}()
+			Eventually(func() int {
+				delegate.mu.Lock()
+				defer delegate.mu.Unlock()
+				return delegate.numberOfQueryCloserPeersOnFrontier
+			}, time.Second).Should(Equal(1))
+			Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+		})
+	})
+
+	Context("when bootstrapping is already in progress", func() {
+
+		It("should return an error without bootstrapping again", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node, seed := nodes[0], nodes[1]
+			node.Options.Timeout = time.Second
+			node.Options.BootstrapMultiAddresses = identity.MultiAddresses{seed.MultiAddress()}
+			silent, err := ListenSilently(seed)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer silent.Close()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- node.Bootstrap()
+			}()
+			Eventually(silent.Connections).Should(BeNumerically(">", 0))
+			Ω(node.Bootstrap()).Should(Equal(swarm.ErrBootstrapInProgress))
+			Eventually(done, 3*time.Second).Should(Receive(Equal(swarm.ErrBootstrapFailed)))
+		})
+	})
+
+	Context("when requiring peers other than the seeds", func() {
+
+		It("should return an error when only the seeds were found", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node, seed := nodes[0], nodes[1]
+			node.Options.RequireNonSeedPeers = true
+			node.Options.BootstrapMultiAddresses = identity.MultiAddresses{seed.MultiAddress()}
+			stop, err := ServeNodes([]*swarm.Node{seed})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			// The seed has no peers, so it cannot introduce the node to any.
+			Ω(node.Bootstrap()).Should(Equal(swarm.ErrOnlySeedPeers))
+			Ω(node.IsBootstrapped()).Should(BeFalse())
+		})
+	})
+
+	Context("when bootstrapping from a peer source", func() {
+
+		It("should ask the peer source for bootstrap nodes", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			source := &countingPeerSource{}
+			nodes[0].Options.PeerSource = source
+			Ω(nodes[0].Bootstrap()).ShouldNot(HaveOccurred())
+			Ω(source.calls).Should(Equal(1))
+		})
+	})
+})
+
+type countingPeerSource struct {
+	calls int
+}
+
+func (source *countingPeerSource) Peers(ctx context.Context) (identity.MultiAddresses, error) {
+	source.calls++
+	return identity.MultiAddresses{}, nil
+}
@@ -0,0 +1,186 @@
+package swarm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+)
+
+// A queryCache stores the responses to queries for a short time, so that
+// repeated queries for the same target identity.Address do not need to
+// search the dht.DHT.
+type queryCache struct {
+	mu      *sync.Mutex
+	entries map[identity.Address]queryCacheEntry
+}
+
+type queryCacheEntry struct {
+	multiAddresses *rpc.MultiAddresses
+	expiry         time.Time
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{
+		mu:      new(sync.Mutex),
+		entries: map[identity.Address]queryCacheEntry{},
+	}
+}
+
+// get returns the cached response for a target identity.Address, if it has
+// not expired.
+func (cache *queryCache) get(target identity.Address) (*rpc.MultiAddresses, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry, ok := cache.entries[target]
+	if !ok || time.Now().After(entry.expiry) {
+		return nil, false
+	}
+	return entry.multiAddresses, true
+}
+
+// put caches the response for a target identity.Address until the ttl has
+// passed. Expired responses are removed. A ttl that is not positive disables
+// caching.
+func (cache *queryCache) put(target identity.Address, multiAddresses *rpc.MultiAddresses, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := time.Now()
+	for address, entry := range cache.entries {
+		if now.After(entry.expiry) {
+			delete(cache.entries, address)
+		}
+	}
+	cache.entries[target] = queryCacheEntry{
+		multiAddresses: multiAddresses,
+		expiry:         now.Add(ttl),
+	}
+}
+
+// A lookupCache stores the results of lookups for a short time, so that
+// repeatedly looking up the same target identity.Address does not traverse
+// the network each time.
+type lookupCache struct {
+	mu      *sync.Mutex
+	entries map[identity.Address]lookupCacheEntry
+}
+
+type lookupCacheEntry struct {
+	k              int
+	multiAddresses identity.MultiAddresses
+	expiry         time.Time
+}
+
+func newLookupCache() *lookupCache {
+	return &lookupCache{
+		mu:      new(sync.Mutex),
+		entries: map[identity.Address]lookupCacheEntry{},
+	}
+}
+
+// get returns the k closest identity.MultiAddresses from a cached lookup of
+// the target identity.Address, if it has not expired and it looked up at
+// least k identity.MultiAddresses.
+func (cache *lookupCache) get(target identity.Address, k int) (identity.MultiAddresses, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry, ok := cache.entries[target]
+	if !ok || entry.k < k || time.Now().After(entry.expiry) {
+		return nil, false
+	}
+	return truncate(append(identity.MultiAddresses{}, entry.multiAddresses...), k), true
+}
+
+// put caches the result of a lookup until the ttl has passed. Expired results
+// are removed. A ttl that is not positive disables caching.
+func (cache *lookupCache) put(target identity.Address, k int, multiAddresses identity.MultiAddresses, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := time.Now()
+	for address, entry := range cache.entries {
+		if now.After(entry.expiry) {
+			delete(cache.entries, address)
+		}
+	}
+	cache.entries[target] = lookupCacheEntry{
+		k:              k,
+		multiAddresses: append(identity.MultiAddresses{}, multiAddresses...),
+		expiry:         now.Add(ttl),
+	}
+}
+
+// invalidate removes the cached lookup of an identity.Address, and all cached
+// lookups that found it.
+func (cache *lookupCache) invalidate(address identity.Address) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	delete(cache.entries, address)
+	for target, entry := range cache.entries {
+		for _, multiAddress := range entry.multiAddresses {
+			if multiAddress.Address() == address {
+				delete(cache.entries, target)
+				break
+			}
+		}
+	}
+}
+
+// A pingCache stores the results of pings for a short time, so that a peer
+// that is pinged by different parts of the Node in quick succession is only
+// pinged once.
+type pingCache struct {
+	mu      *sync.Mutex
+	entries map[identity.Address]pingCacheEntry
+}
+
+type pingCacheEntry struct {
+	err    error
+	expiry time.Time
+}
+
+func newPingCache() *pingCache {
+	return &pingCache{
+		mu:      new(sync.Mutex),
+		entries: map[identity.Address]pingCacheEntry{},
+	}
+}
+
+// get returns the result of the last ping to an identity.Address, if it has
+// not expired.
+func (cache *pingCache) get(address identity.Address) (pingCacheEntry, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry, ok := cache.entries[address]
+	if !ok || time.Now().After(entry.expiry) {
+		return pingCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// put caches the result of a ping to an identity.Address until the ttl has
+// passed. Expired results are removed. A ttl that is not positive disables
+// caching.
+func (cache *pingCache) put(address identity.Address, err error, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := time.Now()
+	for cached, entry := range cache.entries {
+		if now.After(entry.expiry) {
+			delete(cache.entries, cached)
+		}
+	}
+	cache.entries[address] = pingCacheEntry{
+		err:    err,
+		expiry: now.Add(ttl),
+	}
+}
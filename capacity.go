@@ -0,0 +1,80 @@
+package swarm
+
+import (
+	"github.com/republicprotocol/go-identity"
+)
+
+// checkCapacityUnsafe returns ErrTableFull if adding an identity.MultiAddress
+// to the dht.DHT would exceed the MaxPeers option. When the EvictionPolicy
+// option is EvictionMostCrowded, the oldest peer in the most crowded bucket is
+// returned instead, as long as that bucket would still hold more peers than
+// the bucket of the new peer. It must be evicted once the new peer has been
+// added. The same eviction is used for a new peer that would be the first in
+// its bucket when the ProtectBucketFillers option is set, whatever the
+// EvictionPolicy. Peers that are already in the dht.DHT can always be updated.
+// The dhtMu must be locked for writing.
+func (node *Node) checkCapacityUnsafe(multiAddress identity.MultiAddress) (*identity.MultiAddress, error) {
+	options := node.options()
+	if options.MaxPeers <= 0 {
+		return nil, nil
+	}
+	current, err := node.findMultiAddress(multiAddress.Address())
+	if err != nil || current != nil {
+		return nil, err
+	}
+	lengths := node.bucketLengthsUnsafe()
+	numberOfPeers := 0
+	for _, length := range lengths {
+		numberOfPeers += length
+	}
+	if numberOfPeers < options.MaxPeers {
+		return nil, nil
+	}
+
+	prefix, err := node.Address().SamePrefixLength(multiAddress.Address())
+	if err != nil {
+		return nil, err
+	}
+	if prefix < 0 || prefix >= len(lengths) {
+		return nil, ErrInvalidBucketIndex
+	}
+	// A peer that would be the first in its bucket extends the coverage of
+	// the dht.DHT, so it is worth evicting a peer from a crowded bucket.
+	filling := options.ProtectBucketFillers && lengths[prefix] == 0
+	if options.EvictionPolicy != EvictionMostCrowded && !filling {
+		return nil, ErrTableFull
+	}
+	// A full bucket is handled by the FullBucketStrategy option, so there is
+	// no point evicting a peer from another bucket.
+	if options.MaxBucketLength > 0 && lengths[prefix] >= options.MaxBucketLength {
+		return nil, nil
+	}
+	crowded := 0
+	for i := range lengths {
+		if lengths[i] > lengths[crowded] {
+			crowded = i
+		}
+	}
+	if lengths[crowded]-1 <= lengths[prefix] {
+		return nil, ErrTableFull
+	}
+	oldest, err := node.oldestMultiAddressUnsafe(node.bucketAddresses[crowded])
+	if err != nil {
+		return nil, err
+	}
+	if oldest == nil {
+		return nil, ErrTableFull
+	}
+	return oldest, nil
+}
+
+// bucketLengthsUnsafe returns the number of peers in each bucket of the
+// dht.DHT, indexed by the number of prefix bits that they share with the Node.
+// The dhtMu must be locked.
+func (node *Node) bucketLengthsUnsafe() []int {
+	lengths := make([]int, len(node.bucketAddresses))
+	for i := range lengths {
+		lengths[i] = len(node.bucketUnsafe(i))
+	}
+	return lengths
+}
@@ -0,0 +1,218 @@
+package swarm
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+	"golang.org/x/net/context"
+)
+
+// Constants for the methods that are passed to the OutboundMiddleware.
+const (
+	MethodPing                       = "Ping"
+	MethodQueryCloserPeers           = "QueryCloserPeers"
+	MethodQueryCloserPeersOnFrontier = "QueryCloserPeersOnFrontier"
+)
+
+// OutboundMiddleware is called before each outbound RPC made by a Node. The
+// context returned is used for the RPC, allowing metadata to be attached to
+// the RPC.
+type OutboundMiddleware func(ctx context.Context, method string, target identity.MultiAddress) context.Context
+
+// pingTarget pings an identity.MultiAddress and records the round trip time
+// of the ping. If the identity.MultiAddress was pinged within the
+// MinPingInterval option, the result of that ping is returned instead.
+func (node *Node) pingTarget(ctx context.Context, target identity.MultiAddress) error {
+	if entry, ok := node.pingCache.get(target.Address()); ok {
+		return entry.err
+	}
+	err := node.pingTargetUncached(ctx, target)
+	// A ping that was cancelled says nothing about the liveness of the peer.
+	if ctx.Err() == nil {
+		node.pingCache.put(target.Address(), err, node.options().MinPingInterval)
+	}
+	return err
+}
+
+func (node *Node) pingTargetUncached(ctx context.Context, target identity.MultiAddress) error {
+	ctx = node.outbound(ctx, MethodPing, target)
+	if err := node.schedule(ctx); err != nil {
+		return err
+	}
+	conn, err := node.dial(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	begin := time.Now()
+	if _, err := rpc.NewSwarmNodeClient(conn).Ping(ctx, rpc.SerializeMultiAddress(node.MultiAddress())); err != nil {
+		return err
+	}
+	node.updateRTT(target.Address(), time.Since(begin))
+	return nil
+}
+
+// queryCloserPeersFromTarget queries an identity.MultiAddress for peers that
+// are closer to the target identity.Address, and records the round trip time
+// of the query.
+func (node *Node) queryCloserPeersFromTarget(ctx context.Context, peer identity.MultiAddress, target identity.Address) (identity.MultiAddresses, error) {
+	ctx = node.outbound(ctx, MethodQueryCloserPeers, peer)
+	conn, err := node.dial(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return node.queryCloserPeersOnConn(ctx, rpc.NewSwarmNodeClient(conn), peer, target)
+}
+
+// QueryCloserPeersForTargets queries a peer for the peers that are closer to
+// each of the target identity.Addresses. One QueryCloserPeers RPC is sent for
+// each target, but all of them share one connection, so this saves the cost
+// of dialing the peer for each target, but not the cost of answering each RPC.
+// At most Alpha queries are made at the same time, and the results are
+// returned by target. Targets whose query failed are not in the results, and
+// the first error is returned. Returns ErrBatchTooLarge if there are more
+// targets than the MaxBatchTargets option allows. The OutboundMiddleware
+// option is called once for each target.
+func (node *Node) QueryCloserPeersForTargets(ctx context.Context, peer identity.MultiAddress, targets []identity.Address) (map[identity.Address]identity.MultiAddresses, error) {
+	if maxBatchTargets := node.options().MaxBatchTargets; maxBatchTargets > 0 && len(targets) > maxBatchTargets {
+		return nil, ErrBatchTooLarge
+	}
+	conn, err := node.dial(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	client := rpc.NewSwarmNodeClient(conn)
+
+	concurrency := node.Alpha()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	mu := new(sync.Mutex)
+	results := make(map[identity.Address]identity.MultiAddresses, len(targets))
+	var firstErr error
+	wg := new(sync.WaitGroup)
+	for _, target := range targets {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			if firstErr == nil {
+				firstErr = ctx.Err()
+			}
+			return results, firstErr
+		}
+		wg.Add(1)
+		go func(target identity.Address) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			peers, err := node.queryCloserPeersOnConn(node.outbound(ctx, MethodQueryCloserPeers, peer), client, peer, target)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			results[target] = peers
+		}(target)
+	}
+	wg.Wait()
+	return results, firstErr
+}
+
+// queryCloserPeersOnConn queries a peer for peers that are closer to the
+// target identity.Address, using an existing client.
+func (node *Node) queryCloserPeersOnConn(ctx context.Context, client rpc.SwarmNodeClient, peer identity.MultiAddress, target identity.Address) (identity.MultiAddresses, error) {
+	if err := node.schedule(ctx); err != nil {
+		return nil, err
+	}
+	begin := time.Now()
+	multiAddresses, err := client.QueryCloserPeers(ctx, node.query(target))
+	if err != nil {
+		return nil, err
+	}
+	node.updateRTT(peer.Address(), time.Since(begin))
+
+	peers := make(identity.MultiAddresses, 0, len(multiAddresses.Multis))
+	for _, serialized := range multiAddresses.Multis {
+		multiAddress, err := deserializeMultiAddress(serialized)
+		if err != nil {
+			return peers, err
+		}
+		// A peer can return the Node when the Node is closer to the target
+		// than the peer. Otherwise, the peer is misbehaving.
+		if multiAddress.Address() == node.Address() && target != node.Address() {
+			if closer, err := identity.Closer(node.Address(), peer.Address(), target); err == nil && !closer {
+				node.reportSelfAddress(peer.Address())
+			}
+		}
+		peers = append(peers, multiAddress)
+	}
+	return peers, nil
+}
+
+// queryCloserPeersOnFrontierFromTarget queries an identity.MultiAddress for
+// all peers that it can reach that are closer to the target identity.Address.
+func (node *Node) queryCloserPeersOnFrontierFromTarget(ctx context.Context, peer identity.MultiAddress, target identity.Address) (identity.MultiAddresses, error) {
+	ctx = node.outbound(ctx, MethodQueryCloserPeersOnFrontier, peer)
+	if err := node.schedule(ctx); err != nil {
+		return nil, err
+	}
+	conn, err := node.dial(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	stream, err := rpc.NewSwarmNodeClient(conn).QueryCloserPeersOnFrontier(ctx, node.query(target))
+	if err != nil {
+		return nil, err
+	}
+	return receiveFrontier(stream)
+}
+
+// receiveFrontier receives identity.MultiAddresses from the stream of a
+// frontier query until it ends.
+func receiveFrontier(stream rpc.SwarmNode_QueryCloserPeersOnFrontierClient) (identity.MultiAddresses, error) {
+	peers := identity.MultiAddresses{}
+	for {
+		multiAddress, err := stream.Recv()
+		if err == io.EOF {
+			return peers, nil
+		}
+		if err != nil {
+			return peers, err
+		}
+		peer, err := deserializeMultiAddress(multiAddress)
+		if err != nil {
+			return peers, err
+		}
+		peers = append(peers, peer)
+	}
+}
+
+// outbound applies the OutboundMiddleware option, if there is one.
+func (node *Node) outbound(ctx context.Context, method string, target identity.MultiAddress) context.Context {
+	options := node.options()
+	if options.OutboundMiddleware == nil {
+		return ctx
+	}
+	return options.OutboundMiddleware(ctx, method, target)
+}
+
+// query returns an rpc.Query from the Node for the target identity.Address.
+func (node *Node) query(target identity.Address) *rpc.Query {
+	return &rpc.Query{
+		From:  rpc.SerializeMultiAddress(node.MultiAddress()),
+		Query: &rpc.Address{Address: string(target)},
+	}
+}
@@ -0,0 +1,59 @@
+package swarm
+
+import (
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+)
+
+// EncodeMultiAddress returns the encoding of an identity.MultiAddress that is
+// used by the Node on the wire, and when exporting peers. The encoding is the
+// textual form of the identity.MultiAddress, such as
+// "/ip4/127.0.0.1/tcp/18514/republic/8MGfbzAMS59Gb4cSjpm34soGNYsM2f". It does
+// not depend on gRPC, so it can be used to bridge the identity.MultiAddresses
+// of the network into other protocols.
+func EncodeMultiAddress(multiAddress identity.MultiAddress) string {
+	return multiAddress.String()
+}
+
+// DecodeMultiAddress returns the identity.MultiAddress that was encoded by
+// EncodeMultiAddress. Returns ErrInvalidMultiAddress if it cannot be decoded.
+func DecodeMultiAddress(encoded string) (identity.MultiAddress, error) {
+	multiAddress, err := identity.NewMultiAddressFromString(encoded)
+	if err != nil {
+		return identity.MultiAddress{}, ErrInvalidMultiAddress
+	}
+	return multiAddress, nil
+}
+
+// EncodeMultiAddresses returns the encoding of each identity.MultiAddress.
+func EncodeMultiAddresses(multiAddresses identity.MultiAddresses) []string {
+	encoded := make([]string, len(multiAddresses))
+	for i, multiAddress := range multiAddresses {
+		encoded[i] = EncodeMultiAddress(multiAddress)
+	}
+	return encoded
+}
+
+// DecodeMultiAddresses returns the identity.MultiAddresses that were encoded
+// by EncodeMultiAddresses. Returns ErrInvalidMultiAddress if any of them
+// cannot be decoded.
+func DecodeMultiAddresses(encoded []string) (identity.MultiAddresses, error) {
+	multiAddresses := make(identity.MultiAddresses, len(encoded))
+	for i := range encoded {
+		multiAddress, err := DecodeMultiAddress(encoded[i])
+		if err != nil {
+			return nil, err
+		}
+		multiAddresses[i] = multiAddress
+	}
+	return multiAddresses, nil
+}
+
+// deserializeMultiAddress returns ErrInvalidMultiAddress if an
+// rpc.MultiAddress cannot be deserialized.
+func deserializeMultiAddress(multiAddress *rpc.MultiAddress) (identity.MultiAddress, error) {
+	if multiAddress == nil {
+		return identity.MultiAddress{}, ErrInvalidMultiAddress
+	}
+	return DecodeMultiAddress(multiAddress.Multi)
+}
@@ -0,0 +1,103 @@
+package swarm
+
+import (
+	"log"
+	"sync"
+
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+)
+
+// Crawl walks the network breadth first, starting from the peers in the
+// dht.DHT, and returns the peers that each reachable Node returned. Each Node
+// is queried for the identity.Address of this Node, and for a random
+// identity.Address in the far half of the network, and is visited at most
+// once. At most Alpha Nodes are queried at the same time. The crawl stops
+// after maxNodes Nodes, including this Node, have been recorded, unless
+// maxNodes is zero. If the context is done, the topology crawled so far is
+// returned with the error of the context.
+func (node *Node) Crawl(ctx context.Context, maxNodes int) (map[identity.Address]identity.MultiAddresses, error) {
+	topology := map[identity.Address]identity.MultiAddresses{
+		node.Address(): node.DHT.MultiAddresses(),
+	}
+	visited := map[identity.Address]struct{}{node.Address(): {}}
+	frontier := identity.MultiAddresses{}
+	for _, peer := range topology[node.Address()] {
+		visited[peer.Address()] = struct{}{}
+		frontier = append(frontier, peer)
+	}
+	targets := []identity.Address{node.Address(), node.RandomAddress(0)}
+
+	concurrency := node.Alpha()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	for len(frontier) > 0 && (maxNodes <= 0 || len(topology) < maxNodes) {
+		n := len(frontier)
+		if maxNodes > 0 && n > maxNodes-len(topology) {
+			n = maxNodes - len(topology)
+		}
+		level := frontier[:n]
+		frontier = frontier[n:]
+
+		// Query the level, recording nil for Nodes that cannot be reached.
+		neighbors := make([]identity.MultiAddresses, len(level))
+		semaphore := make(chan struct{}, concurrency)
+		wg := new(sync.WaitGroup)
+		wg.Add(len(level))
+		for i, peer := range level {
+			semaphore <- struct{}{}
+			go func(i int, peer identity.MultiAddress) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				neighbors[i] = node.crawlPeer(ctx, peer, targets)
+			}(i, peer)
+		}
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return topology, err
+		}
+
+		for i, peer := range level {
+			if neighbors[i] == nil {
+				continue
+			}
+			topology[peer.Address()] = neighbors[i]
+			for _, neighbor := range neighbors[i] {
+				if _, ok := visited[neighbor.Address()]; ok {
+					continue
+				}
+				visited[neighbor.Address()] = struct{}{}
+				frontier = append(frontier, neighbor)
+			}
+		}
+	}
+	return topology, nil
+}
+
+// crawlPeer queries a peer for each target, and returns the distinct peers
+// that it returned. Returns nil if the peer could not be queried.
+func (node *Node) crawlPeer(ctx context.Context, peer identity.MultiAddress, targets []identity.Address) identity.MultiAddresses {
+	options := node.options()
+	neighbors := identity.MultiAddresses{}
+	seen := map[identity.Address]struct{}{}
+	for _, target := range targets {
+		queryCtx, cancel := context.WithTimeout(ctx, options.Timeout)
+		candidates, err := node.queryCloserPeersFromTarget(queryCtx, peer, target)
+		cancel()
+		if err != nil {
+			if options.Debug >= DebugMedium {
+				log.Printf("%v cannot crawl %v: %v\n", node.Address(), peer, err)
+			}
+			return nil
+		}
+		for _, candidate := range candidates {
+			if _, ok := seen[candidate.Address()]; ok {
+				continue
+			}
+			seen[candidate.Address()] = struct{}{}
+			neighbors = append(neighbors, candidate)
+		}
+	}
+	return neighbors
+}
@@ -0,0 +1,399 @@
+package swarm
+
+import (
+	"bytes"
+	"log"
+	"time"
+
+	"github.com/republicprotocol/go-dht"
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+)
+
+// The dht.DHT panics when a malformed identity.Address produces a bucket index
+// that is out of range. The functions below wrap the dht.DHT so that
+// identity.Addresses received from the network are checked, and return
+// ErrInvalidBucketIndex, before they reach the dht.DHT. Mutations of the
+// dht.DHT made by the Node are serialized by the dhtMu, so that a sequence of
+// reads and mutations can be made atomically.
+
+func (node *Node) findBucket(target identity.Address) (*dht.Bucket, error) {
+	if err := node.checkBucketIndex(target); err != nil {
+		return nil, err
+	}
+	return node.DHT.FindBucket(target)
+}
+
+// checkBucketIndex returns ErrInvalidBucketIndex if the target
+// identity.Address does not belong in any bucket of the dht.DHT, such as when
+// it has the wrong length, and ErrSelfAddress if it is the identity.Address
+// of the Node.
+func (node *Node) checkBucketIndex(target identity.Address) error {
+	if _, err := node.BucketIndex(target); err != nil {
+		if err == ErrSelfAddress {
+			return err
+		}
+		return ErrInvalidBucketIndex
+	}
+	return nil
+}
+
+// BucketIndex returns the index of the bucket in the dht.DHT that the target
+// identity.Address belongs in. Buckets are indexed from the furthest bucket,
+// so the index decreases as the number of prefix bits that the target shares
+// with the Node increases. Returns ErrSelfAddress for the identity.Address of
+// the Node, which does not belong in any bucket.
+func (node *Node) BucketIndex(target identity.Address) (int, error) {
+	if target == node.Address() {
+		return 0, ErrSelfAddress
+	}
+	prefix, err := node.Address().SamePrefixLength(target)
+	if err != nil {
+		return 0, err
+	}
+	index := identity.IDLength*8 - prefix - 1
+	if index < 0 || index >= identity.IDLength*8 {
+		return 0, ErrInvalidBucketIndex
+	}
+	return index, nil
+}
+
+func (node *Node) findMultiAddress(target identity.Address) (*identity.MultiAddress, error) {
+	if err := node.checkBucketIndex(target); err != nil {
+		return nil, err
+	}
+	return node.DHT.FindMultiAddress(target)
+}
+
+// findMultiAddressNeighbors returns the n closest identity.MultiAddresses to
+// the target identity.Address, ordered by their distance to the target. The
+// bucket of the target is searched first, followed by the buckets that are
+// closer to the Node, which are all the same distance from the target. Then
+// the buckets that are further from the Node are searched outward, one at a
+// time, until at least n identity.MultiAddresses have been found.
+func (node *Node) findMultiAddressNeighbors(target identity.Address, n int) (identity.MultiAddresses, error) {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	return node.findMultiAddressNeighborsUnsafe(target, n)
+}
+
+// findMultiAddressNeighborsUnsafe is the same as findMultiAddressNeighbors,
+// but the dhtMu must be locked.
+func (node *Node) findMultiAddressNeighborsUnsafe(target identity.Address, n int) (identity.MultiAddresses, error) {
+	prefix := len(node.bucketAddresses)
+	if target != node.Address() {
+		index, err := node.BucketIndex(target)
+		if err != nil {
+			return nil, ErrInvalidBucketIndex
+		}
+		prefix = len(node.bucketAddresses) - index - 1
+	}
+
+	neighbors := identity.MultiAddresses{}
+	for i := prefix; i < len(node.bucketAddresses); i++ {
+		if i > prefix && len(neighbors) >= n {
+			break
+		}
+		neighbors = append(neighbors, node.bucketUnsafe(i)...)
+	}
+	for i := prefix - 1; i >= 0 && len(neighbors) < n; i-- {
+		neighbors = append(neighbors, node.bucketUnsafe(i)...)
+	}
+	sortByDistance(neighbors, target)
+	return truncate(neighbors, n), nil
+}
+
+// bucketUnsafe returns a copy of the identity.MultiAddresses in the bucket of
+// peers that share the given number of prefix bits with the Node. The dhtMu
+// must be locked.
+func (node *Node) bucketUnsafe(prefix int) identity.MultiAddresses {
+	bucket, err := node.DHT.FindBucket(node.bucketAddresses[prefix])
+	if err != nil || bucket == nil {
+		return nil
+	}
+	multiAddresses := make(identity.MultiAddresses, len(bucket.MultiAddresses))
+	copy(multiAddresses, bucket.MultiAddresses)
+	return multiAddresses
+}
+
+// newBucketAddresses returns an identity.Address in each bucket of a dht.DHT,
+// indexed by the number of prefix bits that it shares with the
+// identity.Address of the dht.DHT, so that buckets can be found by index.
+func newBucketAddresses(address identity.Address) []identity.Address {
+	id := address.ID()
+	addresses := make([]identity.Address, identity.IDLength*8)
+	for prefix := range addresses {
+		flipped := make(identity.ID, len(id))
+		copy(flipped, id)
+		flipped[len(id)-identity.IDLength+prefix/8] ^= 1 << uint(7-prefix%8)
+		addresses[prefix] = flipped.Address()
+	}
+	return addresses
+}
+
+func (node *Node) updateMultiAddress(multiAddress identity.MultiAddress) error {
+	node.dhtMu.Lock()
+	defer node.dhtMu.Unlock()
+	return node.updateMultiAddressUnsafe(multiAddress)
+}
+
+// Contains returns true if the identity.Address is in the dht.DHT, otherwise
+// false. The Node itself is never in its dht.DHT.
+func (node *Node) Contains(address identity.Address) bool {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	multiAddress, err := node.findMultiAddress(address)
+	return err == nil && multiAddress != nil
+}
+
+// UpdateMultiAddresses adds a batch of identity.MultiAddresses to the dht.DHT,
+// or updates them if they are already in the dht.DHT. The batch is inserted
+// while holding the lock of the Node once, instead of once per
+// identity.MultiAddress. Returns an error for each identity.MultiAddress,
+// which is nil if the identity.MultiAddress was inserted.
+func (node *Node) UpdateMultiAddresses(multiAddresses identity.MultiAddresses) []error {
+	node.dhtMu.Lock()
+	defer node.dhtMu.Unlock()
+	errs := make([]error, len(multiAddresses))
+	for i, multiAddress := range multiAddresses {
+		if multiAddress.Address() == node.Address() {
+			errs[i] = ErrSelfAddress
+			continue
+		}
+		errs[i] = node.updateMultiAddressUnsafe(multiAddress)
+	}
+	return errs
+}
+
+// WaitForPeers blocks until there are at least n peers in the dht.DHT, or
+// until the context is done. The Node is notified when it changes the dht.DHT,
+// so this does not poll the dht.DHT.
+func (node *Node) WaitForPeers(ctx context.Context, n int) error {
+	for {
+		node.dhtMu.RLock()
+		numberOfPeers := len(node.DHT.MultiAddresses())
+		changed := node.dhtChanged
+		node.dhtMu.RUnlock()
+		if numberOfPeers >= n {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// PeersWithinRadius returns all identity.MultiAddresses in the dht.DHT whose
+// XOR distance to the target identity.Address is less than, or equal to, the
+// radius. The radius is a big-endian distance, and the
+// identity.MultiAddresses are ordered by their distance to the target.
+func (node *Node) PeersWithinRadius(target identity.Address, radius []byte) (identity.MultiAddresses, error) {
+	peers := identity.MultiAddresses{}
+	for _, multiAddress := range node.DHT.MultiAddresses() {
+		distance, err := multiAddress.Address().Distance(target)
+		if err != nil {
+			if node.options().Debug >= DebugLow {
+				log.Printf("%v cannot compare %v to %v: %v\n", node.Address(), multiAddress.Address(), target, err)
+			}
+			continue
+		}
+		if compareDistance(distance, radius) <= 0 {
+			peers = append(peers, multiAddress)
+		}
+	}
+	sortByDistance(peers, target)
+	return peers, nil
+}
+
+// compareDistance compares two big-endian distances of possibly different
+// lengths. Returns -1 if left is less than right, 1 if left is greater than
+// right, and 0 otherwise.
+func compareDistance(left, right []byte) int {
+	for len(left) < len(right) {
+		if right[0] != 0 {
+			return -1
+		}
+		right = right[1:]
+	}
+	for len(right) < len(left) {
+		if left[0] != 0 {
+			return 1
+		}
+		left = left[1:]
+	}
+	return bytes.Compare(left, right)
+}
+
+// oldestMultiAddress returns a copy of the oldest identity.MultiAddress in the
+// bucket of the target identity.Address, or nil if the bucket is empty.
+func (node *Node) oldestMultiAddress(target identity.Address) (*identity.MultiAddress, error) {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	return node.oldestMultiAddressUnsafe(target)
+}
+
+// removeOldestMultiAddress removes an identity.MultiAddress from the dht.DHT,
+// but only if it is still the oldest identity.MultiAddress in the bucket of
+// the target identity.Address. Returns a boolean indicating whether or not
+// the identity.MultiAddress was removed.
+func (node *Node) removeOldestMultiAddress(target identity.Address, multiAddress identity.MultiAddress) (bool, error) {
+	node.dhtMu.Lock()
+	defer node.dhtMu.Unlock()
+	oldest, err := node.oldestMultiAddressUnsafe(target)
+	if err != nil || oldest == nil || oldest.Address() != multiAddress.Address() {
+		return false, err
+	}
+	return true, node.removeMultiAddressUnsafe(multiAddress)
+}
+
+// removeMultiAddress removes an identity.MultiAddress from the dht.DHT, if it
+// is still in the dht.DHT. Returns a boolean indicating whether or not the
+// identity.MultiAddress was removed.
+func (node *Node) removeMultiAddress(multiAddress identity.MultiAddress) (bool, error) {
+	node.dhtMu.Lock()
+	defer node.dhtMu.Unlock()
+	current, err := node.findMultiAddress(multiAddress.Address())
+	if err != nil || current == nil {
+		return false, err
+	}
+	return true, node.removeMultiAddressUnsafe(multiAddress)
+}
+
+// refreshMultiAddress moves an identity.MultiAddress to the back of its
+// bucket, but only if it is still in the dht.DHT.
+func (node *Node) refreshMultiAddress(multiAddress identity.MultiAddress) error {
+	node.dhtMu.Lock()
+	defer node.dhtMu.Unlock()
+	current, err := node.findMultiAddress(multiAddress.Address())
+	if err != nil || current == nil {
+		return err
+	}
+	return node.updateMultiAddressUnsafe(multiAddress)
+}
+
+// oldestMultiAddressUnsafe returns the identity.MultiAddress in a bucket that
+// has the lowest update sequence. Using the sequence, instead of the order of
+// the bucket, makes the choice robust to adjustments of the clock.
+// identity.MultiAddresses that were not added by the Node are the oldest.
+func (node *Node) oldestMultiAddressUnsafe(target identity.Address) (*identity.MultiAddress, error) {
+	bucket, err := node.findBucket(target)
+	if err != nil || bucket == nil || bucket.Length() == 0 {
+		return nil, err
+	}
+
+	node.peersMu.RLock()
+	defer node.peersMu.RUnlock()
+	oldest := bucket.MultiAddresses[0]
+	oldestSequence := node.sequenceUnsafe(oldest.Address())
+	for _, multiAddress := range bucket.MultiAddresses[1:] {
+		if sequence := node.sequenceUnsafe(multiAddress.Address()); sequence < oldestSequence {
+			oldest, oldestSequence = multiAddress, sequence
+		}
+	}
+	return &oldest, nil
+}
+
+func (node *Node) updateMultiAddressUnsafe(multiAddress identity.MultiAddress) error {
+	if err := node.checkBucketIndex(multiAddress.Address()); err != nil {
+		return err
+	}
+	if err := node.checkSubnetUnsafe(multiAddress); err != nil {
+		return err
+	}
+	evicted, err := node.checkCapacityUnsafe(multiAddress)
+	if err != nil {
+		return err
+	}
+	if err := node.DHT.UpdateMultiAddress(multiAddress); err != nil {
+		return err
+	}
+	// A peer is only evicted once the new peer has been added, so that a
+	// failed update does not lose it. A replacement is not promoted into its
+	// bucket, because the dht.DHT would be over capacity again.
+	if evicted != nil {
+		if err := node.dropMultiAddressUnsafe(*evicted); err != nil && node.options().Debug >= DebugLow {
+			log.Printf("%v cannot evict %v: %v\n", node.Address(), *evicted, err)
+		}
+	}
+	node.sequence++
+	node.peersMu.Lock()
+	p := node.peer(multiAddress.Address())
+	p.sequence = node.sequence
+	if p.added.IsZero() {
+		p.added = time.Now()
+	}
+	node.peersMu.Unlock()
+	node.notifyDHTChangedUnsafe()
+	return nil
+}
+
+// sequenceUnsafe returns the update sequence of an identity.Address. The
+// peersMu must be locked for reading.
+func (node *Node) sequenceUnsafe(address identity.Address) uint64 {
+	if p, ok := node.peers[address]; ok {
+		return p.sequence
+	}
+	return 0
+}
+
+func (node *Node) removeMultiAddressUnsafe(multiAddress identity.MultiAddress) error {
+	if err := node.dropMultiAddressUnsafe(multiAddress); err != nil {
+		return err
+	}
+	go node.promoteReplacement(multiAddress.Address())
+	return nil
+}
+
+// dropMultiAddressUnsafe removes an identity.MultiAddress from the dht.DHT
+// without promoting a replacement into its bucket. The dhtMu must be locked
+// for writing.
+func (node *Node) dropMultiAddressUnsafe(multiAddress identity.MultiAddress) error {
+	if err := node.DHT.RemoveMultiAddress(multiAddress); err != nil {
+		return err
+	}
+	node.forgetMultiAddressUnsafe(multiAddress.Address())
+	return nil
+}
+
+// purgeMultiAddressUnsafe removes every copy of an identity.MultiAddress from
+// every bucket of the dht.DHT, without promoting a replacement. Unlike
+// dropMultiAddressUnsafe, it also removes copies that are in the wrong bucket,
+// which the dht.DHT cannot find. The dhtMu must be locked for writing.
+func (node *Node) purgeMultiAddressUnsafe(multiAddress identity.MultiAddress) {
+	for _, bucketAddress := range node.bucketAddresses {
+		bucket, err := node.DHT.FindBucket(bucketAddress)
+		if err != nil || bucket == nil {
+			continue
+		}
+		kept := bucket.MultiAddresses[:0]
+		for _, candidate := range bucket.MultiAddresses {
+			if candidate.Address() != multiAddress.Address() {
+				kept = append(kept, candidate)
+			}
+		}
+		bucket.MultiAddresses = kept
+	}
+	node.forgetMultiAddressUnsafe(multiAddress.Address())
+}
+
+// forgetMultiAddressUnsafe notifies the Node that an identity.Address has been
+// removed from the dht.DHT, and forgets everything that it knows about the
+// peer. The dhtMu must be locked for writing.
+func (node *Node) forgetMultiAddressUnsafe(address identity.Address) {
+	node.notifyDHTChangedUnsafe()
+	node.peersMu.Lock()
+	delete(node.peers, address)
+	node.peersMu.Unlock()
+	node.lookupCache.invalidate(address)
+}
+
+// notifyDHTChangedUnsafe wakes all goroutines that are waiting for the
+// dht.DHT to change. The dhtMu must be locked for writing.
+func (node *Node) notifyDHTChangedUnsafe() {
+	close(node.dhtChanged)
+	node.dhtChanged = make(chan struct{})
+	node.observeConvergence()
+}
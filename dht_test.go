@@ -0,0 +1,553 @@
+package swarm_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-swarm-network"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var _ = Describe("DHT", func() {
+
+	Context("when updating a batch of multi-addresses", func() {
+
+		It("should return an error for each multi-address", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 4, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			multiAddresses := identity.MultiAddresses{}
+			for _, node := range nodes {
+				multiAddresses = append(multiAddresses, node.MultiAddress())
+			}
+
+			errs := nodes[0].UpdateMultiAddresses(multiAddresses)
+			Ω(errs).Should(HaveLen(len(nodes)))
+			Ω(errs[0]).Should(Equal(swarm.ErrSelfAddress))
+			for i := 1; i < len(nodes); i++ {
+				Ω(errs[i]).ShouldNot(HaveOccurred())
+			}
+			Ω(nodes[0].DHT.MultiAddresses()).Should(HaveLen(len(nodes) - 1))
+		})
+	})
+
+	Context("when given a malformed address", func() {
+
+		It("should return an error instead of reaching the dht", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = nodes[0].BucketIndex(identity.Address("malformed"))
+			Ω(err).Should(HaveOccurred())
+			Ω(nodes[0].Contains(identity.Address("malformed"))).Should(BeFalse())
+			_, err = nodes[0].Resolve(context.Background(), identity.Address("malformed"))
+			Ω(err).Should(Equal(swarm.ErrInvalidBucketIndex))
+		})
+
+		It("should return an error when it is queried for closer peers", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			server, client := nodes[0], nodes[1]
+			stop, err := ServeNodes([]*swarm.Node{server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			_, err = client.QueryCloserPeersForTargets(context.Background(), server.MultiAddress(), []identity.Address{identity.Address("malformed")})
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(swarm.ErrInvalidBucketIndex.Error()))
+
+			// The server is still answering queries.
+			_, err = client.QueryCloserPeersForTargets(context.Background(), server.MultiAddress(), []identity.Address{client.Address()})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("when querying peers while the dht is updated", func() {
+
+		It("should not race with the updates", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 16, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			server, client := nodes[0], nodes[1]
+			stop, err := ServeNodes([]*swarm.Node{server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			wg := new(sync.WaitGroup)
+			wg.Add(len(nodes) - 2)
+			for _, node := range nodes[2:] {
+				go func(node *swarm.Node) {
+					defer wg.Done()
+					server.AddPeer(node.MultiAddress())
+				}(node)
+			}
+			targets := make([]identity.Address, 8)
+			for i := range targets {
+				targets[i] = nodes[i+2].Address()
+			}
+			_, err = client.QueryCloserPeersForTargets(context.Background(), server.MultiAddress(), targets)
+			wg.Wait()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("when waiting for peers", func() {
+
+		It("should return when enough peers have been added", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(100 * time.Millisecond)
+				nodes[0].UpdateMultiAddresses(identity.MultiAddresses{nodes[1].MultiAddress(), nodes[2].MultiAddress()})
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			Ω(nodes[0].WaitForPeers(ctx, 2)).ShouldNot(HaveOccurred())
+		})
+
+		It("should return an error when the context is done", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			Ω(nodes[0].WaitForPeers(ctx, 1)).Should(Equal(context.DeadlineExceeded))
+		})
+	})
+
+	Context("when finding the bucket index of an address", func() {
+
+		It("should return an error for its own address", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = nodes[0].BucketIndex(nodes[0].Address())
+			Ω(err).Should(Equal(swarm.ErrSelfAddress))
+
+			index, err := nodes[0].BucketIndex(nodes[1].Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(index).Should(BeNumerically(">=", 0))
+		})
+	})
+
+	Context("when checking for a multi-address", func() {
+
+		It("should only contain peers that have been added", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(nodes[0].Contains(nodes[1].Address())).Should(BeFalse())
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].Contains(nodes[1].Address())).Should(BeTrue())
+			Ω(nodes[0].Contains(nodes[0].Address())).Should(BeFalse())
+		})
+	})
+
+	Context("when finding peers within a radius", func() {
+
+		It("should only return peers that are close enough", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].UpdateMultiAddresses(identity.MultiAddresses{nodes[1].MultiAddress(), nodes[2].MultiAddress()})
+
+			radius, err := nodes[1].Address().Distance(nodes[0].Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			peers, err := nodes[0].PeersWithinRadius(nodes[0].Address(), radius)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(ContainElement(nodes[1].MultiAddress()))
+
+			peers, err = nodes[0].PeersWithinRadius(nodes[0].Address(), []byte{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(BeEmpty())
+		})
+	})
+
+	Context("when validating the DHT", func() {
+
+		It("should not return errors for a consistent DHT", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 4, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			for _, node := range nodes[1:] {
+				Ω(nodes[0].AddPeer(node.MultiAddress())).ShouldNot(HaveOccurred())
+			}
+			Ω(nodes[0].ValidateDHT(true)).Should(BeEmpty())
+			Ω(nodes[0].DHT.MultiAddresses()).Should(HaveLen(3))
+		})
+
+		It("should move a misplaced peer into the right bucket once", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			bucket, err := nodes[0].DHT.FindBucket(nodes[0].RandomAddress(200))
+			Ω(err).ShouldNot(HaveOccurred())
+			bucket.MultiAddresses = append(bucket.MultiAddresses, nodes[1].MultiAddress())
+
+			Ω(nodes[0].ValidateDHT(false)).Should(HaveLen(1))
+			Ω(nodes[0].ValidateDHT(true)).Should(HaveLen(1))
+			Ω(nodes[0].DHT.MultiAddresses()).Should(HaveLen(1))
+			Ω(nodes[0].Contains(nodes[1].Address())).Should(BeTrue())
+			Ω(nodes[0].ValidateDHT(false)).Should(BeEmpty())
+		})
+	})
+
+	Context("when the routing table is full", func() {
+
+		It("should reject new peers by default", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.MaxPeers = 1
+
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].AddPeer(nodes[2].MultiAddress())).Should(Equal(swarm.ErrTableFull))
+			Ω(nodes[0].DHT.MultiAddresses()).Should(HaveLen(1))
+		})
+
+		It("should evict from the most crowded bucket", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 32, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.MaxPeers = 2
+			nodes[0].Options.EvictionPolicy = swarm.EvictionMostCrowded
+
+			buckets := map[int][]*swarm.Node{}
+			for _, node := range nodes[1:] {
+				index, err := nodes[0].BucketIndex(node.Address())
+				Ω(err).ShouldNot(HaveOccurred())
+				buckets[index] = append(buckets[index], node)
+			}
+			var crowded, sparse []*swarm.Node
+			for _, bucket := range buckets {
+				if len(bucket) >= 2 && crowded == nil {
+					crowded = bucket
+				} else if sparse == nil {
+					sparse = bucket
+				}
+			}
+			Ω(crowded).ShouldNot(BeNil())
+			Ω(sparse).ShouldNot(BeNil())
+
+			Ω(nodes[0].AddPeer(crowded[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].AddPeer(crowded[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].AddPeer(sparse[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].DHT.MultiAddresses()).Should(HaveLen(2))
+			Ω(nodes[0].Contains(crowded[0].Address())).Should(BeFalse())
+			Ω(nodes[0].Contains(sparse[0].Address())).Should(BeTrue())
+
+			// The buckets are now balanced, so there is nothing to evict.
+			if len(crowded) > 2 {
+				Ω(nodes[0].AddPeer(crowded[2].MultiAddress())).Should(Equal(swarm.ErrTableFull))
+			}
+		})
+
+		It("should never exceed the maximum number of peers when adding peers concurrently", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 32, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.MaxPeers = 4
+			nodes[0].Options.EvictionPolicy = swarm.EvictionMostCrowded
+
+			wg := new(sync.WaitGroup)
+			wg.Add(len(nodes) - 1)
+			for _, node := range nodes[1:] {
+				go func(node *swarm.Node) {
+					defer wg.Done()
+					nodes[0].AddPeer(node.MultiAddress())
+				}(node)
+			}
+			wg.Wait()
+			Ω(len(nodes[0].DHT.MultiAddresses())).Should(BeNumerically("<=", 4))
+			Ω(nodes[0].ValidateDHT(false)).Should(BeEmpty())
+		})
+
+		It("should not promote a replacement into the bucket of an evicted peer", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 32, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node := swarm.NewNode(grpc.NewServer(), newMockDelegate(), swarm.Options{
+				MultiAddress:       nodes[0].MultiAddress(),
+				MaxBucketLength:    2,
+				MaxPeers:           2,
+				EvictionPolicy:     swarm.EvictionMostCrowded,
+				FullBucketStrategy: swarm.FullBucketReplaceCache,
+				Timeout:            time.Second,
+			})
+
+			buckets := map[int][]*swarm.Node{}
+			for _, peer := range nodes[1:] {
+				index, err := node.BucketIndex(peer.Address())
+				Ω(err).ShouldNot(HaveOccurred())
+				buckets[index] = append(buckets[index], peer)
+			}
+			var crowded, sparse []*swarm.Node
+			for _, bucket := range buckets {
+				if len(bucket) >= 3 && crowded == nil {
+					crowded = bucket
+				} else if sparse == nil {
+					sparse = bucket
+				}
+			}
+			Ω(crowded).ShouldNot(BeNil())
+			Ω(sparse).ShouldNot(BeNil())
+
+			// The third peer in the crowded bucket is stored as a replacement,
+			// and is served so that it could be promoted.
+			stop, err := ServeNodes(crowded[2:3])
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			Ω(node.AddPeer(crowded[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.AddPeer(crowded[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.AddPeer(crowded[2].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.Contains(crowded[2].Address())).Should(BeFalse())
+
+			Ω(node.AddPeer(sparse[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.Contains(crowded[0].Address())).Should(BeFalse())
+			Ω(node.Contains(sparse[0].Address())).Should(BeTrue())
+			Consistently(func() bool {
+				return node.Contains(crowded[2].Address())
+			}, 500*time.Millisecond).Should(BeFalse())
+			Ω(node.DHT.MultiAddresses()).Should(HaveLen(2))
+		})
+
+		It("should admit peers that fill an empty bucket when protecting them", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 32, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.MaxPeers = 2
+			nodes[0].Options.ProtectBucketFillers = true
+
+			buckets := map[int][]*swarm.Node{}
+			for _, node := range nodes[1:] {
+				index, err := nodes[0].BucketIndex(node.Address())
+				Ω(err).ShouldNot(HaveOccurred())
+				buckets[index] = append(buckets[index], node)
+			}
+			var crowded, sparse []*swarm.Node
+			for _, bucket := range buckets {
+				if len(bucket) >= 3 && crowded == nil {
+					crowded = bucket
+				} else if sparse == nil {
+					sparse = bucket
+				}
+			}
+			Ω(crowded).ShouldNot(BeNil())
+			Ω(sparse).ShouldNot(BeNil())
+
+			Ω(nodes[0].AddPeer(crowded[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].AddPeer(crowded[1].MultiAddress())).ShouldNot(HaveOccurred())
+			// The bucket is not empty, so the default policy rejects the peer.
+			Ω(nodes[0].AddPeer(crowded[2].MultiAddress())).Should(Equal(swarm.ErrTableFull))
+			Ω(nodes[0].AddPeer(sparse[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].DHT.MultiAddresses()).Should(HaveLen(2))
+			Ω(nodes[0].Contains(sparse[0].Address())).Should(BeTrue())
+		})
+	})
+
+	Context("when a bucket is full", func() {
+
+		// fullBucket returns a Node with a MaxBucketLength of one, and two
+		// peers that belong to the same bucket. The first peer has been added
+		// to the Node.
+		fullBucket := func(strategy string) (*swarm.Node, *swarm.Node, *swarm.Node) {
+			nodes, err := GenerateNodes(NodePortSwarm, 16, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node := swarm.NewNode(grpc.NewServer(), newMockDelegate(), swarm.Options{
+				MultiAddress:       nodes[0].MultiAddress(),
+				MaxBucketLength:    1,
+				Timeout:            time.Second,
+				FullBucketStrategy: strategy,
+				OutboundMiddleware: func(ctx context.Context, method string, target identity.MultiAddress) context.Context {
+					ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+					time.AfterFunc(200*time.Millisecond, cancel)
+					return ctx
+				},
+			})
+
+			buckets := map[int]*swarm.Node{}
+			for _, peer := range nodes[1:] {
+				index, err := node.BucketIndex(peer.Address())
+				Ω(err).ShouldNot(HaveOccurred())
+				if first, ok := buckets[index]; ok {
+					Ω(node.AddPeer(first.MultiAddress())).ShouldNot(HaveOccurred())
+					return node, first, peer
+				}
+				buckets[index] = peer
+			}
+			Fail("no two peers belong to the same bucket")
+			return nil, nil, nil
+		}
+
+		// sameBucket returns n of the peers that belong to the same bucket of
+		// the node.
+		sameBucket := func(node *swarm.Node, peers []*swarm.Node, n int) []*swarm.Node {
+			buckets := map[int][]*swarm.Node{}
+			for _, peer := range peers {
+				index, err := node.BucketIndex(peer.Address())
+				Ω(err).ShouldNot(HaveOccurred())
+				buckets[index] = append(buckets[index], peer)
+				if len(buckets[index]) == n {
+					return buckets[index]
+				}
+			}
+			Fail("not enough peers belong to the same bucket")
+			return nil
+		}
+
+		It("should drop the new peer when rejecting", func() {
+			node, oldest, newest := fullBucket(swarm.FullBucketReject)
+			Ω(node.AddPeer(newest.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.Contains(oldest.Address())).Should(BeTrue())
+			Ω(node.Contains(newest.Address())).Should(BeFalse())
+		})
+
+		It("should skip the new peer when importing", func() {
+			node, oldest, newest := fullBucket(swarm.FullBucketForceNewest)
+			added, err := node.ImportPeers(strings.NewReader(newest.MultiAddress().String()))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(added).Should(Equal(0))
+
+			Ω(oldest.AddPeer(newest.MultiAddress())).ShouldNot(HaveOccurred())
+			state, err := oldest.ExportState()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(node.ImportState(state)).ShouldNot(HaveOccurred())
+
+			Ω(node.Contains(oldest.Address())).Should(BeTrue())
+			Ω(node.Contains(newest.Address())).Should(BeFalse())
+		})
+
+		It("should replace the oldest peer when forcing the newest", func() {
+			node, oldest, newest := fullBucket(swarm.FullBucketForceNewest)
+			Ω(node.AddPeer(newest.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.Contains(oldest.Address())).Should(BeFalse())
+			Ω(node.Contains(newest.Address())).Should(BeTrue())
+		})
+
+		It("should replace the least recently updated peer when forcing the newest", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 32, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node := swarm.NewNode(grpc.NewServer(), newMockDelegate(), swarm.Options{
+				MultiAddress:       nodes[0].MultiAddress(),
+				MaxBucketLength:    3,
+				FullBucketStrategy: swarm.FullBucketForceNewest,
+			})
+			peers := sameBucket(node, nodes[1:], 4)
+
+			// A peer that was not added by the node is the oldest, whatever
+			// its position in the bucket.
+			Ω(node.AddPeer(peers[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.DHT.UpdateMultiAddress(peers[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.AddPeer(peers[2].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.AddPeer(peers[3].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.Contains(peers[1].Address())).Should(BeFalse())
+
+			// Updating a peer makes it the newest, so the peer after it is
+			// replaced next.
+			Ω(node.AddPeer(peers[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.AddPeer(peers[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.Contains(peers[0].Address())).Should(BeTrue())
+			Ω(node.Contains(peers[2].Address())).Should(BeFalse())
+		})
+
+		It("should promote the new peer from the replacement cache", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			node, oldest, newest := fullBucket(swarm.FullBucketReplaceCache)
+			Ω(node.AddPeer(newest.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.Contains(newest.Address())).Should(BeFalse())
+
+			// The oldest peer is not served, so pruning removes it, and the
+			// newest peer is served, so it is promoted in its place.
+			stop, err := ServeNodes([]*swarm.Node{newest})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			Ω(node.Prune(oldest.Address())).Should(BeTrue())
+			Ω(node.Contains(oldest.Address())).Should(BeFalse())
+			Eventually(func() bool {
+				return node.Contains(newest.Address())
+			}, 2*time.Second).Should(BeTrue())
+		})
+
+		It("should skip a dead replacement and promote a live one", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 32, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node := swarm.NewNode(grpc.NewServer(), newMockDelegate(), swarm.Options{
+				MultiAddress:       nodes[0].MultiAddress(),
+				MaxBucketLength:    2,
+				Timeout:            time.Second,
+				FullBucketStrategy: swarm.FullBucketReplaceCache,
+				OutboundMiddleware: func(ctx context.Context, method string, target identity.MultiAddress) context.Context {
+					ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+					time.AfterFunc(200*time.Millisecond, cancel)
+					return ctx
+				},
+			})
+
+			// Two of the peers fill the bucket, and two of them are
+			// replacements.
+			peers := sameBucket(node, nodes[1:], 4)
+			oldest, live, dead := peers[0], peers[2], peers[3]
+			for _, peer := range peers {
+				Ω(node.AddPeer(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			}
+			Ω(node.Contains(live.Address())).Should(BeFalse())
+			Ω(node.Contains(dead.Address())).Should(BeFalse())
+
+			// The dead replacement is the most recently seen, so it is pinged
+			// first, but it never responds.
+			silent, err := ListenSilently(dead)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer silent.Close()
+			stop, err := ServeNodes([]*swarm.Node{live})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			Ω(node.Prune(oldest.Address())).Should(BeTrue())
+			Eventually(func() bool {
+				return node.Contains(live.Address())
+			}, 2*time.Second).Should(BeTrue())
+			Ω(node.Contains(dead.Address())).Should(BeFalse())
+			Ω(silent.Connections()).Should(BeNumerically(">", 0))
+		})
+	})
+})
+
+func BenchmarkUpdateMultiAddress(b *testing.B) {
+	node, multiAddresses := generateBenchmarkMultiAddresses(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, multiAddress := range multiAddresses {
+			node.DHT.UpdateMultiAddress(multiAddress)
+		}
+	}
+}
+
+func BenchmarkUpdateMultiAddresses(b *testing.B) {
+	node, multiAddresses := generateBenchmarkMultiAddresses(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node.UpdateMultiAddresses(multiAddresses)
+	}
+}
+
+func generateBenchmarkMultiAddresses(b *testing.B) (*swarm.Node, identity.MultiAddresses) {
+	nodes, err := GenerateNodes(NodePortSwarm, 256, newMockDelegate())
+	if err != nil {
+		b.Fatal(err)
+	}
+	multiAddresses := make(identity.MultiAddresses, 0, len(nodes)-1)
+	for _, node := range nodes[1:] {
+		multiAddresses = append(multiAddresses, node.MultiAddress())
+	}
+	return nodes[0], multiAddresses
+}
@@ -0,0 +1,48 @@
+package swarm
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// dial a gRPC connection to an identity.MultiAddress.
+func (node *Node) dial(ctx context.Context, target identity.MultiAddress) (*grpc.ClientConn, error) {
+	address, err := dialAddress(target)
+	if err != nil {
+		return nil, err
+	}
+	exhausted := int32(0)
+	dialOptions := []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock(), grpc.WithDialer(resourceDialer(&exhausted))}
+	if node.options().EnableCompression {
+		dialOptions = append(dialOptions, grpc.WithCompressor(grpc.NewGZIPCompressor()), grpc.WithDecompressor(grpc.NewGZIPDecompressor()))
+	}
+	conn, err := grpc.DialContext(ctx, address, dialOptions...)
+	if err != nil && atomic.LoadInt32(&exhausted) == 1 {
+		return nil, node.recordResourceError()
+	}
+	return conn, err
+}
+
+// dialAddress returns the host and port of an identity.MultiAddress. Returns
+// ErrInvalidMultiAddress if either of them is missing.
+func dialAddress(multiAddress identity.MultiAddress) (string, error) {
+	var host, port string
+	protocols := strings.Split(multiAddress.String(), "/")
+	for i := 1; i+1 < len(protocols); i += 2 {
+		switch protocols[i] {
+		case "ip4", "ip6", "dns4", "dns6":
+			host = protocols[i+1]
+		case "tcp":
+			port = protocols[i+1]
+		}
+	}
+	if host == "" || port == "" {
+		return "", ErrInvalidMultiAddress
+	}
+	return net.JoinHostPort(host, port), nil
+}
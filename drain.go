@@ -0,0 +1,49 @@
+package swarm
+
+import (
+	"google.golang.org/grpc/metadata"
+)
+
+// DrainingMetadataKey is the gRPC header that is sent in response to RPCs
+// while the Node is draining.
+const DrainingMetadataKey = "swarm-draining"
+
+// Drain the Node. A draining Node continues to respond to RPCs, but it does
+// not add the peers that send them to its dht.DHT, and it sends the
+// DrainingMetadataKey header so that peers can route around it. Draining is
+// used before calling Close, to reduce disruption when the Node is shutdown.
+func (node *Node) Drain() {
+	node.stateMu.Lock()
+	defer node.stateMu.Unlock()
+	node.draining = true
+}
+
+// IsDraining returns true if the Node is draining, otherwise false.
+func (node *Node) IsDraining() bool {
+	node.stateMu.RLock()
+	defer node.stateMu.RUnlock()
+	return node.draining
+}
+
+// Close the Node by stopping its background maintenance and its gRPC server.
+// RPCs that are in progress are allowed to finish.
+func (node *Node) Close() {
+	node.stateMu.Lock()
+	if !node.closed {
+		node.closed = true
+		close(node.done)
+	}
+	node.stateMu.Unlock()
+	if node.Server != nil {
+		node.Server.GracefulStop()
+	}
+}
+
+// drainingHeader returns the gRPC header that is sent in response to RPCs, or
+// nil if the Node is not draining.
+func (node *Node) drainingHeader() metadata.MD {
+	if !node.IsDraining() {
+		return nil
+	}
+	return metadata.Pairs(DrainingMetadataKey, "true")
+}
@@ -0,0 +1,21 @@
+package swarm_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Closing", func() {
+
+	Context("when the node was never served", func() {
+
+		It("should close without a server", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(nodes[0].Close).ShouldNot(Panic())
+
+			nodes[1].Server = nil
+			Ω(nodes[1].Close).ShouldNot(Panic())
+		})
+	})
+})
@@ -0,0 +1,132 @@
+package swarm
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrPeerNotFound is returned when an identity.Address cannot be found in the
+// dht.DHT, or in the network.
+var ErrPeerNotFound = errors.New("peer not found")
+
+// ErrBootstrapFailed is returned when none of the bootstrap
+// identity.MultiAddresses could be used to bootstrap a Node.
+var ErrBootstrapFailed = errors.New("bootstrap failed")
+
+// ErrOnlySeedPeers is returned when a Node bootstraps, but does not find any
+// peers other than the bootstrap Nodes.
+var ErrOnlySeedPeers = errors.New("only seed peers")
+
+// ErrInsufficientCoverage is returned when a Node bootstraps, but cannot find
+// peers in as many buckets as the MinBucketsCovered option requires.
+var ErrInsufficientCoverage = errors.New("insufficient bucket coverage")
+
+// ErrBootstrapInProgress is returned when a Node is asked to bootstrap while
+// it is already bootstrapping.
+var ErrBootstrapInProgress = errors.New("bootstrap in progress")
+
+// ErrValueNotFound is returned when no Value is stored under a key.
+var ErrValueNotFound = errors.New("value not found")
+
+// ErrLookupLoop is returned when a lookup does not end within the maximum
+// number of rounds, such as when peers keep returning each other.
+var ErrLookupLoop = errors.New("lookup loop")
+
+// ErrInvalidMultiAddress is returned when an identity.MultiAddress cannot be
+// deserialized, or cannot be dialed.
+var ErrInvalidMultiAddress = errors.New("invalid multi-address")
+
+// ErrSelfAddress is returned when an operation that expects the
+// identity.Address of a peer is given the identity.Address of the Node.
+var ErrSelfAddress = errors.New("self address")
+
+// ErrObservedAddressUnavailable is returned when a peer does not report the
+// network address that it observed the Node connecting from.
+var ErrObservedAddressUnavailable = errors.New("observed address unavailable")
+
+// ErrPeerInfoUnavailable is returned when a peer does not report its version
+// and start time.
+var ErrPeerInfoUnavailable = errors.New("peer info unavailable")
+
+// ErrStatsUnavailable is returned when a peer does not report the occupancy
+// of its dht.DHT, because its ExposeStats option is not set.
+var ErrStatsUnavailable = errors.New("stats unavailable")
+
+// ErrNeighborhoodUnavailable is returned when a peer does not acknowledge a
+// subscription to a neighborhood.
+var ErrNeighborhoodUnavailable = errors.New("neighborhood unavailable")
+
+// ErrInvalidAddress is returned when an identity.Address cannot be decoded
+// into an identity.ID.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ErrInvalidAddressLength is returned when the identity.ID of an
+// identity.Address does not have a length of identity.IDLength bytes.
+var ErrInvalidAddressLength = errors.New("invalid address length")
+
+// ErrImmutableOption is returned when SetOptions is used to change an option
+// that cannot be changed after a Node has been created.
+var ErrImmutableOption = errors.New("immutable option")
+
+// ErrDuplicatePeer is returned when an identity.MultiAddress appears in the
+// dht.DHT more than once.
+var ErrDuplicatePeer = errors.New("duplicate peer")
+
+// ErrMisplacedPeer is returned when an identity.MultiAddress is not in the
+// bucket of the dht.DHT that its identity.Address belongs in.
+var ErrMisplacedPeer = errors.New("misplaced peer")
+
+// ErrBatchTooLarge is returned when QueryCloserPeersForTargets is given more
+// targets than the MaxBatchTargets option allows.
+var ErrBatchTooLarge = errors.New("batch too large")
+
+// ErrInvalidBucketIndex is returned when an identity.Address maps to a bucket
+// index that is out of range in the dht.DHT.
+var ErrInvalidBucketIndex = errors.New("invalid bucket index")
+
+// ErrClientDisconnected is returned when a streaming RPC cannot send to its
+// client, because the client has gone away.
+var ErrClientDisconnected = errors.New("client disconnected")
+
+// ErrFrontierIncomplete is returned by a strict frontier query when too many
+// peers could not be queried for the result to be reliable.
+var ErrFrontierIncomplete = errors.New("frontier incomplete")
+
+// ErrFrontierStatsUnavailable is returned when a peer does not send the
+// FrontierStats of a frontier query, such as when it runs an older version.
+var ErrFrontierStatsUnavailable = errors.New("frontier stats unavailable")
+
+// ErrSubnetLimit is returned when adding a peer to the dht.DHT would exceed
+// the maximum number of peers in the same subnet.
+var ErrSubnetLimit = errors.New("subnet limit reached")
+
+// ErrTableFull is returned when adding a peer to the dht.DHT would exceed the
+// MaxPeers option, and the EvictionPolicy option does not make space for it.
+var ErrTableFull = errors.New("routing table full")
+
+// ErrPeerRejected is returned when a peer is rejected by the PeerFilter
+// option.
+var ErrPeerRejected = errors.New("peer rejected")
+
+// ErrResourceExhausted is returned when a Node cannot open a connection to a
+// peer because it has run out of file descriptors. This is distinct from a
+// peer being unreachable.
+var ErrResourceExhausted = errors.New("resource exhausted")
+
+// rpcError converts an error into a gRPC status error with a code that
+// describes the error. Errors without a known code are returned unchanged.
+func rpcError(err error) error {
+	switch err {
+	case ErrPeerNotFound, ErrValueNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case ErrInvalidMultiAddress, ErrInvalidAddress, ErrSelfAddress, ErrInvalidBucketIndex:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case ErrBootstrapFailed, ErrFrontierIncomplete:
+		return status.Error(codes.Unavailable, err.Error())
+	case ErrClientDisconnected:
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+package swarm
+
+import (
+	"google.golang.org/grpc/metadata"
+)
+
+// ExhaustedMetadataKey is the gRPC header that is sent in response to a query
+// when the Node has no peers. It lets the client distinguish a Node that knows
+// no peers from a Node that knows no peers closer to the target, so that the
+// client can stop querying it.
+const ExhaustedMetadataKey = "swarm-exhausted"
+
+// exhaustedHeader returns the gRPC header that is sent in response to a query,
+// or nil if the Node has peers.
+func (node *Node) exhaustedHeader() metadata.MD {
+	if len(node.DHT.MultiAddresses()) > 0 {
+		return nil
+	}
+	return metadata.Pairs(ExhaustedMetadataKey, "true")
+}
@@ -0,0 +1,59 @@
+package swarm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// ExportPeers writes the identity.MultiAddress of each peer in the dht.DHT to
+// the io.Writer, one per line.
+func (node *Node) ExportPeers(w io.Writer) error {
+	for _, multiAddress := range node.DHT.MultiAddresses() {
+		if _, err := fmt.Fprintln(w, EncodeMultiAddress(multiAddress)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportPeers reads identity.MultiAddresses from the io.Reader, one per line,
+// and adds each one to the dht.DHT. Returns the number of peers that were
+// added. Blank lines are ignored. Lines that cannot be added, including peers
+// in a full bucket, are skipped and counted, instead of stopping the import.
+// No peers are pruned, so the import does not use the network. An error is
+// only returned if the io.Reader fails.
+func (node *Node) ImportPeers(r io.Reader) (added int, err error) {
+	options := node.options()
+	node.dhtMu.Lock()
+	defer node.dhtMu.Unlock()
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		multiAddress, err := DecodeMultiAddress(line)
+		if err == nil {
+			err = node.checkPeer(multiAddress)
+		}
+		if err == nil {
+			err = node.updateMultiAddressUnsafe(multiAddress)
+		}
+		if err != nil {
+			if options.Debug >= DebugLow {
+				log.Printf("%v cannot import %v: %v\n", node.Address(), line, err)
+			}
+			skipped++
+			continue
+		}
+		added++
+	}
+	if skipped > 0 && options.Debug >= DebugLow {
+		log.Printf("%v skipped %v peers while importing\n", node.Address(), skipped)
+	}
+	return added, scanner.Err()
+}
@@ -0,0 +1,86 @@
+package swarm_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-swarm-network"
+)
+
+var _ = Describe("Peer export and import", func() {
+
+	var nodes []*swarm.Node
+
+	BeforeEach(func() {
+		var err error
+		nodes, err = GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	Context("when exporting peers", func() {
+
+		It("should import the peers into another node", func() {
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			buffer := new(bytes.Buffer)
+			Ω(nodes[0].ExportPeers(buffer)).ShouldNot(HaveOccurred())
+
+			added, err := nodes[2].ImportPeers(buffer)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(added).Should(Equal(1))
+			Ω(nodes[2].DHT.MultiAddresses()).Should(HaveLen(1))
+		})
+	})
+
+	Context("when importing malformed lines", func() {
+
+		It("should skip them and continue", func() {
+			input := strings.Join([]string{"not a multi-address", "", nodes[1].MultiAddress().String()}, "\n")
+			added, err := nodes[0].ImportPeers(strings.NewReader(input))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(added).Should(Equal(1))
+		})
+
+		It("should skip its own multi-address", func() {
+			added, err := nodes[0].ImportPeers(strings.NewReader(nodes[0].MultiAddress().String()))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(added).Should(Equal(0))
+		})
+	})
+
+	Context("when handing off state", func() {
+
+		It("should restore the peers and their metadata", func() {
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].SetMetadata(nodes[1].Address(), map[string]string{"role": "relay"})).ShouldNot(HaveOccurred())
+			state, err := nodes[0].ExportState()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(nodes[2].ImportState(state)).ShouldNot(HaveOccurred())
+			Ω(nodes[2].DHT.MultiAddresses()).Should(HaveLen(1))
+			Ω(nodes[2].Metadata(nodes[1].Address())).Should(Equal(map[string]string{"role": "relay"}))
+		})
+
+		It("should return an error for malformed state", func() {
+			Ω(nodes[0].ImportState([]byte("not json"))).Should(HaveOccurred())
+		})
+	})
+
+	Context("when encoding multi-addresses", func() {
+
+		It("should decode the encoded multi-addresses", func() {
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			encoded := swarm.EncodeMultiAddresses(nodes[0].DHT.MultiAddresses())
+			decoded, err := swarm.DecodeMultiAddresses(encoded)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(decoded).Should(HaveLen(1))
+			Ω(decoded[0].String()).Should(Equal(nodes[1].MultiAddress().String()))
+		})
+
+		It("should return an error for malformed encodings", func() {
+			_, err := swarm.DecodeMultiAddress("not a multi-address")
+			Ω(err).Should(Equal(swarm.ErrInvalidMultiAddress))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+package swarm
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// PeerAgesMetadataKey is the gRPC header that is sent in response to a query.
+// It has one value for each rpc.MultiAddress in the response, in the same
+// order, which is the number of milliseconds since the Node last contacted
+// the peer. The value is -1 when the Node has not contacted the peer.
+const PeerAgesMetadataKey = "swarm-peer-ages"
+
+// LastSeen returns the time of the last successful ping or query between the
+// Node and a peer, in either direction. Returns the zero time.Time if the Node
+// has not contacted the identity.Address.
+func (node *Node) LastSeen(address identity.Address) time.Time {
+	node.peersMu.RLock()
+	defer node.peersMu.RUnlock()
+	if p, ok := node.peers[address]; ok {
+		return p.lastSeen
+	}
+	return time.Time{}
+}
+
+// Added returns the time at which a peer was added to the dht.DHT. Unlike
+// LastSeen, it is not changed by contact with the peer. Returns the zero
+// time.Time if the identity.Address was never added.
+func (node *Node) Added(address identity.Address) time.Time {
+	node.peersMu.RLock()
+	defer node.peersMu.RUnlock()
+	if p, ok := node.peers[address]; ok {
+		return p.added
+	}
+	return time.Time{}
+}
+
+// peerAgesHeader returns the gRPC header that reports the age of each
+// rpc.MultiAddress in a response, so that the client can ping the freshest
+// peers first.
+func (node *Node) peerAgesHeader(multiAddresses *rpc.MultiAddresses) metadata.MD {
+	if multiAddresses == nil || len(multiAddresses.Multis) == 0 {
+		return nil
+	}
+	now := time.Now()
+	header := metadata.MD{}
+	for _, multi := range multiAddresses.Multis {
+		age := int64(-1)
+		if multiAddress, err := deserializeMultiAddress(multi); err == nil {
+			if lastSeen := node.LastSeen(multiAddress.Address()); !lastSeen.IsZero() {
+				age = int64(now.Sub(lastSeen) / time.Millisecond)
+			}
+		}
+		header[PeerAgesMetadataKey] = append(header[PeerAgesMetadataKey], strconv.FormatInt(age, 10))
+	}
+	return header
+}
+
+// Constants for the actions recommended by a StalenessReport.
+const (
+	StalenessActionNone    = "none"
+	StalenessActionRefresh = "refresh"
+	StalenessActionPrune   = "prune"
+)
+
+// A StalenessReport summarizes how recently the peers in the dht.DHT have
+// been seen.
+type StalenessReport struct {
+	// NumberOfPeers is the number of peers in the dht.DHT, and
+	// NumberOfStalePeers is the number of those peers that have not been
+	// seen within the StalenessTTL option.
+	NumberOfPeers      int
+	NumberOfStalePeers int
+
+	// OldestAge is the time since the least recently seen peer was seen.
+	// Peers that the Node has never contacted are as old as the time since
+	// they were added.
+	OldestAge time.Duration
+
+	// Action is the recommended maintenance. StalenessActionRefresh is
+	// recommended when some peers are stale, and StalenessActionPrune when
+	// most peers are stale.
+	Action string
+}
+
+// StalenessReport returns a StalenessReport for the peers in the dht.DHT,
+// so that an operator or controller can decide when to run maintenance.
+func (node *Node) StalenessReport() StalenessReport {
+	options := node.options()
+	now := time.Now()
+	node.stateMu.RLock()
+	uptime := now.Sub(node.started)
+	node.stateMu.RUnlock()
+
+	multiAddresses := node.DHT.MultiAddresses()
+	report := StalenessReport{NumberOfPeers: len(multiAddresses), Action: StalenessActionNone}
+	for _, multiAddress := range multiAddresses {
+		age := uptime
+		if lastSeen := node.LastSeen(multiAddress.Address()); !lastSeen.IsZero() {
+			age = now.Sub(lastSeen)
+		} else if added := node.Added(multiAddress.Address()); !added.IsZero() {
+			age = now.Sub(added)
+		}
+		if age > report.OldestAge {
+			report.OldestAge = age
+		}
+		if options.StalenessTTL > 0 && age > options.StalenessTTL {
+			report.NumberOfStalePeers++
+		}
+	}
+
+	switch {
+	case report.NumberOfStalePeers == 0:
+	case 2*report.NumberOfStalePeers > report.NumberOfPeers:
+		report.Action = StalenessActionPrune
+	default:
+		report.Action = StalenessActionRefresh
+	}
+	return report
+}
@@ -0,0 +1,117 @@
+package swarm
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// FrontierStatsMetadataKey is the gRPC metadata that a client sends with a
+// frontier query to ask for FrontierStats. The stats are sent in the gRPC
+// trailer of the stream, using the FrontierVisitedMetadataKey,
+// FrontierFailuresMetadataKey, FrontierDepthMetadataKey, and
+// FrontierElapsedMetadataKey. Clients that do not ask for the stats do not
+// receive them.
+const (
+	FrontierStatsMetadataKey    = "swarm-frontier-stats"
+	FrontierVisitedMetadataKey  = "swarm-frontier-visited"
+	FrontierFailuresMetadataKey = "swarm-frontier-failures"
+	FrontierDepthMetadataKey    = "swarm-frontier-depth"
+	FrontierElapsedMetadataKey  = "swarm-frontier-elapsed"
+)
+
+// FrontierStats describe how a frontier query traversed the network, so that
+// a client can assess whether the peers returned are complete, and decide
+// whether to retry.
+type FrontierStats struct {
+	// Visited is the number of peers that were queried.
+	Visited int
+
+	// Failures is the number of queries to peers that failed.
+	Failures int
+
+	// MaxDepth is the largest number of hops from the queried Node to a peer
+	// that was found. Peers in the dht.DHT of the queried Node are one hop
+	// away.
+	MaxDepth int
+
+	// Elapsed is how long the traversal took.
+	Elapsed time.Duration
+}
+
+// QueryCloserPeersOnFrontierWithStats queries a peer for all peers that it can
+// reach that are closer to the target identity.Address, and returns the
+// FrontierStats of the traversal. Returns ErrFrontierStatsUnavailable, with
+// the peers, if the peer does not send the stats.
+func (node *Node) QueryCloserPeersOnFrontierWithStats(ctx context.Context, peer identity.MultiAddress, target identity.Address) (identity.MultiAddresses, FrontierStats, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Join(md, metadata.Pairs(FrontierStatsMetadataKey, "true")))
+	ctx = node.outbound(ctx, MethodQueryCloserPeersOnFrontier, peer)
+	if err := node.schedule(ctx); err != nil {
+		return nil, FrontierStats{}, err
+	}
+	conn, err := node.dial(ctx, peer)
+	if err != nil {
+		return nil, FrontierStats{}, err
+	}
+	defer conn.Close()
+
+	stream, err := rpc.NewSwarmNodeClient(conn).QueryCloserPeersOnFrontier(ctx, node.query(target))
+	if err != nil {
+		return nil, FrontierStats{}, err
+	}
+	peers, err := receiveFrontier(stream)
+	if err != nil {
+		return peers, FrontierStats{}, err
+	}
+	stats, err := parseFrontierStats(stream.Trailer())
+	return peers, stats, err
+}
+
+// wantsFrontierStats returns true if the client of a frontier query asked for
+// FrontierStats.
+func wantsFrontierStats(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md[FrontierStatsMetadataKey]) > 0 && md[FrontierStatsMetadataKey][0] == "true"
+}
+
+// trailer returns the gRPC trailer that sends the FrontierStats.
+func (stats FrontierStats) trailer() metadata.MD {
+	return metadata.Pairs(
+		FrontierVisitedMetadataKey, strconv.Itoa(stats.Visited),
+		FrontierFailuresMetadataKey, strconv.Itoa(stats.Failures),
+		FrontierDepthMetadataKey, strconv.Itoa(stats.MaxDepth),
+		FrontierElapsedMetadataKey, stats.Elapsed.String(),
+	)
+}
+
+func parseFrontierStats(trailer metadata.MD) (FrontierStats, error) {
+	stats := FrontierStats{}
+	for key, value := range map[string]*int{
+		FrontierVisitedMetadataKey:  &stats.Visited,
+		FrontierFailuresMetadataKey: &stats.Failures,
+		FrontierDepthMetadataKey:    &stats.MaxDepth,
+	} {
+		if len(trailer[key]) == 0 {
+			return FrontierStats{}, ErrFrontierStatsUnavailable
+		}
+		n, err := strconv.Atoi(trailer[key][0])
+		if err != nil {
+			return FrontierStats{}, ErrFrontierStatsUnavailable
+		}
+		*value = n
+	}
+	if len(trailer[FrontierElapsedMetadataKey]) == 0 {
+		return FrontierStats{}, ErrFrontierStatsUnavailable
+	}
+	elapsed, err := time.ParseDuration(trailer[FrontierElapsedMetadataKey][0])
+	if err != nil {
+		return FrontierStats{}, ErrFrontierStatsUnavailable
+	}
+	stats.Elapsed = elapsed
+	return stats, nil
+}
@@ -0,0 +1,282 @@
+package swarm_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-swarm-network"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+var _ = Describe("Frontier queries", func() {
+
+	// setupFrontier generates nodes and a target, and returns the nodes
+	// sorted by their distance to the target. The last node is the server,
+	// and every other node is closer to the target than it is.
+	setupFrontier := func(numberOfNodes int, delegate *mockDelegate) ([]*swarm.Node, identity.Address) {
+		nodes, err := GenerateNodes(NodePortFrontier, numberOfNodes, delegate)
+		Ω(err).ShouldNot(HaveOccurred())
+		keyPair, err := identity.NewKeyPair()
+		Ω(err).ShouldNot(HaveOccurred())
+		SortNodesByDistance(nodes, keyPair.Address())
+		return nodes, keyPair.Address()
+	}
+
+	// hang replaces the nodes with SilentListeners, and adds them to the
+	// server.
+	hang := func(server *swarm.Node, nodes []*swarm.Node) []*SilentListener {
+		silent := make([]*SilentListener, len(nodes))
+		for i, node := range nodes {
+			listener, err := ListenSilently(node)
+			Ω(err).ShouldNot(HaveOccurred())
+			silent[i] = listener
+			Ω(server.AddPeer(node.MultiAddress())).ShouldNot(HaveOccurred())
+		}
+		return silent
+	}
+
+	closeAll := func(silent []*SilentListener) {
+		for _, listener := range silent {
+			listener.Close()
+		}
+	}
+
+	frontierQueries := func(delegate *mockDelegate) func() int {
+		return func() int {
+			delegate.mu.Lock()
+			defer delegate.mu.Unlock()
+			return delegate.numberOfQueryCloserPeersOnFrontier
+		}
+	}
+
+	Context("when the client goes away", func() {
+
+		It("should stop the traversal without notifying the delegate", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			delegate := newMockDelegate()
+			nodes, target := setupFrontier(4, delegate)
+			client, server := nodes[2], nodes[3]
+			stop, err := ServeNodes([]*swarm.Node{client, server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			defer closeAll(hang(server, nodes[:2]))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(200*time.Millisecond, cancel)
+			_, _, err = client.QueryCloserPeersOnFrontierWithStats(ctx, server.MultiAddress(), target)
+			Ω(err).Should(HaveOccurred())
+			Consistently(frontierQueries(delegate), 1500*time.Millisecond).Should(Equal(0))
+		})
+	})
+
+	Context("when the client does not set a deadline", func() {
+
+		It("should stop the traversal at the default handler timeout", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, target := setupFrontier(5, newMockDelegate())
+			client, server := nodes[3], nodes[4]
+			server.Options.DefaultHandlerTimeout = 200 * time.Millisecond
+			stop, err := ServeNodes([]*swarm.Node{client, server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			defer closeAll(hang(server, nodes[:3]))
+
+			begin := time.Now()
+			client.QueryCloserPeersOnFrontierWithStats(context.Background(), server.MultiAddress(), target)
+			Ω(time.Since(begin)).Should(BeNumerically("<", time.Second))
+		})
+	})
+
+	Context("when a query to a peer in the frontier fails", func() {
+
+		query := func(strict bool) (identity.MultiAddresses, error) {
+			nodes, target := setupFrontier(3, newMockDelegate())
+			client, server := nodes[1], nodes[2]
+			server.Options.StrictFrontier = strict
+			stop, err := ServeNodes([]*swarm.Node{client, server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			defer closeAll(hang(server, nodes[:1]))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			peers, _, err := client.QueryCloserPeersOnFrontierWithStats(ctx, server.MultiAddress(), target)
+			return peers, err
+		}
+
+		It("should return the peers found so far by default", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			peers, err := query(false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(HaveLen(1))
+		})
+
+		It("should return an error when the frontier is strict", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			_, err := query(true)
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when limiting the number of queries", func() {
+
+		It("should stop after the maximum number of queries", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, target := setupFrontier(4, newMockDelegate())
+			client, server := nodes[2], nodes[3]
+			server.Options.MaxFrontierQueries = 1
+			stop, err := ServeNodes([]*swarm.Node{client, server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			defer closeAll(hang(server, nodes[:2]))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			peers, stats, err := client.QueryCloserPeersOnFrontierWithStats(ctx, server.MultiAddress(), target)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(HaveLen(2))
+			Ω(stats.Visited).Should(Equal(1))
+			Ω(stats.Failures).Should(Equal(1))
+		})
+	})
+
+	Context("when a round does not find a closer peer", func() {
+
+		// The server knows the closest and the third closest peers. Only the
+		// third closest peer knows the second closest peer, which is not
+		// closer than the closest peer found so far.
+		query := func(convergent bool) swarm.FrontierStats {
+			nodes, target := setupFrontier(5, newMockDelegate())
+			closest, second, third, client, server := nodes[0], nodes[1], nodes[2], nodes[3], nodes[4]
+			server.Options.ConvergentFrontier = convergent
+			Ω(server.AddPeer(closest.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(server.AddPeer(third.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(third.AddPeer(second.MultiAddress())).ShouldNot(HaveOccurred())
+			stop, err := ServeNodes(nodes)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			peers, stats, err := client.QueryCloserPeersOnFrontierWithStats(ctx, server.MultiAddress(), target)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(HaveLen(3))
+			return stats
+		}
+
+		It("should keep exploring by default", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			Ω(query(false).Visited).Should(Equal(3))
+		})
+
+		It("should stop exploring when the frontier is convergent", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			Ω(query(true).Visited).Should(Equal(2))
+		})
+	})
+
+	Context("when reporting stats", func() {
+
+		It("should send the stats of the traversal to the client", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, target := setupFrontier(4, newMockDelegate())
+			client, server := nodes[2], nodes[3]
+			Ω(server.AddPeer(nodes[0].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(server.AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			stop, err := ServeNodes(nodes)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			peers, stats, err := client.QueryCloserPeersOnFrontierWithStats(ctx, server.MultiAddress(), target)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(HaveLen(2))
+			Ω(stats.Visited).Should(Equal(2))
+			Ω(stats.Failures).Should(Equal(0))
+			Ω(stats.MaxDepth).Should(Equal(1))
+			Ω(stats.Elapsed).Should(BeNumerically(">", 0))
+		})
+
+		It("should not send the stats to a client that does not ask for them", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, target := setupFrontier(3, newMockDelegate())
+			client, server := nodes[1], nodes[2]
+			Ω(server.AddPeer(nodes[0].MultiAddress())).ShouldNot(HaveOccurred())
+			stop, err := ServeNodes(nodes)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			// Drop the metadata that asks for the stats.
+			client.Options.OutboundMiddleware = func(ctx context.Context, method string, target identity.MultiAddress) context.Context {
+				return metadata.NewOutgoingContext(ctx, metadata.MD{})
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			peers, _, err := client.QueryCloserPeersOnFrontierWithStats(ctx, server.MultiAddress(), target)
+			Ω(err).Should(Equal(swarm.ErrFrontierStatsUnavailable))
+			Ω(peers).Should(HaveLen(1))
+		})
+	})
+
+	Context("when the frontier has many peers", func() {
+
+		It("should give slow peers the whole hop timeout", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, target := setupFrontier(12, newMockDelegate())
+			client, server := nodes[10], nodes[11]
+			server.Options.Alpha = 10
+			server.Options.StrictFrontier = true
+			nodes[0].Delegate = &slowDelegate{mockDelegate: newMockDelegate(), delay: 400 * time.Millisecond}
+			for _, node := range nodes[:10] {
+				Ω(server.AddPeer(node.MultiAddress())).ShouldNot(HaveOccurred())
+			}
+			stop, err := ServeNodes(nodes)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			// Sharing the deadline between the ten peers in the frontier
+			// would not leave enough time for the closest peer.
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			peers, stats, err := client.QueryCloserPeersOnFrontierWithStats(ctx, server.MultiAddress(), target)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(HaveLen(10))
+			Ω(stats.Failures).Should(Equal(0))
+		})
+	})
+})
+
+// slowDelegate delays its response to queries for closer peers.
+type slowDelegate struct {
+	*mockDelegate
+	delay time.Duration
+}
+
+func (delegate *slowDelegate) OnQueryCloserPeersReceived(from identity.MultiAddress) {
+	time.Sleep(delegate.delay)
+	delegate.mockDelegate.OnQueryCloserPeersReceived(from)
+}
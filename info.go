@@ -0,0 +1,97 @@
+package swarm
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+)
+
+// NodeInfo is a snapshot of the configuration and state of a Node.
+type NodeInfo struct {
+	Address                 identity.Address
+	MultiAddress            identity.MultiAddress
+	BootstrapMultiAddresses identity.MultiAddresses
+	Alpha                   int
+	Version                 string
+
+	// NumberOfPeers is the number of peers in the dht.DHT, and BucketLengths
+	// is the number of peers that share each number of prefix bits with the
+	// Node.
+	NumberOfPeers int
+	BucketLengths []int
+
+	Uptime       time.Duration
+	Bootstrapped bool
+
+	// ConvergenceTime is the time from the first call to Bootstrap until the
+	// dht.DHT first had at least MinPeers peers. It is zero until then.
+	ConvergenceTime time.Duration
+
+	// ResourceErrors is the number of outbound connections that failed
+	// because the Node ran out of file descriptors.
+	ResourceErrors uint64
+}
+
+// Info returns a snapshot of the configuration and state of the Node.
+func (node *Node) Info() NodeInfo {
+	options := node.options()
+	node.dhtMu.RLock()
+	peers := node.DHT.MultiAddresses()
+	node.dhtMu.RUnlock()
+	bucketLengths := node.bucketLengths(peers)
+
+	node.stateMu.RLock()
+	defer node.stateMu.RUnlock()
+	return NodeInfo{
+		Address:                 node.Address(),
+		MultiAddress:            node.MultiAddress(),
+		BootstrapMultiAddresses: append(identity.MultiAddresses{}, options.BootstrapMultiAddresses...),
+		Alpha:                   node.Alpha(),
+		NumberOfPeers:           len(peers),
+		BucketLengths:           bucketLengths,
+		Version:                 options.Version,
+		Uptime:                  time.Since(node.started),
+		Bootstrapped:            node.bootstrapped,
+		ConvergenceTime:         node.convergenceTime,
+		ResourceErrors:          atomic.LoadUint64(&node.resourceErrors),
+	}
+}
+
+// bucketLengths returns the number of identity.MultiAddresses that share each
+// number of prefix bits with the Node.
+func (node *Node) bucketLengths(peers identity.MultiAddresses) []int {
+	bucketLengths := make([]int, identity.IDLength*8)
+	for _, peer := range peers {
+		prefix, err := node.Address().SamePrefixLength(peer.Address())
+		if err != nil || prefix < 0 || prefix >= len(bucketLengths) {
+			continue
+		}
+		bucketLengths[prefix]++
+	}
+	return bucketLengths
+}
+
+// observeConvergence records the ConvergenceTime the first time that the
+// dht.DHT has at least MinPeers peers after Bootstrap has been called. It is
+// called whenever the Node changes the dht.DHT, so growth after bootstrapping
+// is also observed.
+func (node *Node) observeConvergence() {
+	options := node.options()
+	minPeers := options.MinPeers
+	if minPeers < 1 {
+		minPeers = 1
+	}
+	node.stateMu.Lock()
+	defer node.stateMu.Unlock()
+	if node.bootstrapStarted.IsZero() || node.convergenceTime != 0 {
+		return
+	}
+	if len(node.DHT.MultiAddresses()) >= minPeers {
+		node.convergenceTime = time.Since(node.bootstrapStarted)
+		if options.Debug >= DebugMedium {
+			log.Printf("%v converged after %v\n", node.Address(), node.convergenceTime)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package swarm
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-do"
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+)
+
+// Resolve returns the identity.MultiAddress for an identity.Address. The
+// dht.DHT is checked first and, if the identity.Address is not found, the
+// network is searched using an iterative lookup. Returns ErrPeerNotFound if
+// the identity.Address cannot be found.
+func (node *Node) Resolve(ctx context.Context, address identity.Address) (identity.MultiAddress, error) {
+	if address == node.Address() {
+		return node.MultiAddress(), nil
+	}
+	multiAddress, err := node.findMultiAddress(address)
+	if err != nil {
+		return identity.MultiAddress{}, err
+	}
+	if multiAddress != nil {
+		return *multiAddress, nil
+	}
+
+	peers, err := node.Lookup(ctx, address, node.Alpha())
+	for _, peer := range peers {
+		if peer.Address() == address {
+			return peer, nil
+		}
+	}
+	if err != nil {
+		return identity.MultiAddress{}, err
+	}
+	return identity.MultiAddress{}, ErrPeerNotFound
+}
+
+// Lookup iteratively queries peers for identity.MultiAddresses that are closer
+// to the target identity.Address, starting from the peers in the dht.DHT. In
+// each round, up to Alpha of the k closest peers that have not been queried
+// are queried concurrently. The lookup ends when all of the k closest peers
+// have been queried, and returns them ordered by their distance to the target.
+// If the lookup has not ended after MaxLookupRounds rounds, the closest peers
+// found are returned with ErrLookupLoop. Results are cached for the
+// LookupCacheTTL option.
+func (node *Node) Lookup(ctx context.Context, target identity.Address, k int) (identity.MultiAddresses, error) {
+	if peers, ok := node.lookupCache.get(target, k); ok {
+		return peers, nil
+	}
+	peers, err := node.lookup(ctx, target, k, nil)
+	if err == nil && len(peers) > 0 {
+		node.lookupCache.put(target, k, peers, node.options().LookupCacheTTL)
+	}
+	return peers, err
+}
+
+// InvalidateLookup removes the cached lookup of an identity.Address, and all
+// cached lookups that found it, so that the next lookup traverses the
+// network.
+func (node *Node) InvalidateLookup(address identity.Address) {
+	node.lookupCache.invalidate(address)
+}
+
+// A LookupHop is a query made by a lookup.
+type LookupHop struct {
+	// Peer is the identity.MultiAddress that was queried.
+	Peer identity.MultiAddress
+
+	// CloserPeers is the number of peers returned by the query.
+	CloserPeers int
+
+	// RTT is the round trip time of the query.
+	RTT time.Duration
+
+	// Err is the error returned by the query, or nil if it succeeded.
+	Err error
+}
+
+// LookupWithPath is the same as Lookup, but it also returns a LookupHop for
+// each query that was made, in the order that the queries finished.
+func (node *Node) LookupWithPath(ctx context.Context, target identity.Address, k int) (identity.MultiAddresses, []LookupHop, error) {
+	hops := []LookupHop{}
+	peers, err := node.lookup(ctx, target, k, func(hop LookupHop) {
+		hops = append(hops, hop)
+	})
+	return peers, hops, err
+}
+
+// lookup runs an iterative lookup. The hop function, if it is not nil, is
+// called after each query while holding the lock of the lookup.
+func (node *Node) lookup(ctx context.Context, target identity.Address, k int, hop func(LookupHop)) (identity.MultiAddresses, error) {
+	options := node.options()
+	alpha := node.Alpha()
+	if alpha < 1 {
+		alpha = 1
+	}
+	if k < 1 {
+		k = 1
+	}
+	peers, err := node.findMultiAddressNeighbors(target, k)
+	if err != nil {
+		return nil, err
+	}
+
+	mu := new(sync.Mutex)
+	seen := map[identity.Address]struct{}{node.Address(): struct{}{}}
+	queried := map[identity.Address]struct{}{}
+	failed := map[identity.Address]struct{}{}
+	closest := make(identity.MultiAddresses, 0, len(peers))
+	for _, peer := range peers {
+		if _, ok := seen[peer.Address()]; ok {
+			continue
+		}
+		seen[peer.Address()] = struct{}{}
+		closest = append(closest, peer)
+	}
+	sortByDistance(closest, target)
+
+	maxRounds := options.MaxLookupRounds
+	if maxRounds < 1 {
+		maxRounds = DefaultMaxLookupRounds
+	}
+	for rounds := 0; ; rounds++ {
+		if err := ctx.Err(); err != nil {
+			return truncate(closest, k), err
+		}
+		// Peers that keep returning each other, or that are cancelled by
+		// hedging and queried again, cannot keep the lookup running forever.
+		if rounds >= maxRounds {
+			if options.Debug >= DebugLow {
+				log.Printf("%v stopped looking up %v after %v rounds\n", node.Address(), target, rounds)
+			}
+			return truncate(closest, k), ErrLookupLoop
+		}
+
+		// Select the closest peers that have not been queried.
+		width := alpha
+		if options.HedgeFactor > 1 {
+			width = options.HedgeFactor
+		}
+		round := make(identity.MultiAddresses, 0, width)
+		for i := 0; i < len(closest) && i < k && len(round) < width; i++ {
+			if _, ok := queried[closest[i].Address()]; ok {
+				continue
+			}
+			queried[closest[i].Address()] = struct{}{}
+			if node.IsQuarantined(closest[i].Address()) {
+				continue
+			}
+			round = append(round, closest[i])
+		}
+		if len(round) == 0 {
+			break
+		}
+
+		// When hedging, the round ends as soon as one peer responds, and the
+		// queries to the other peers are cancelled.
+		roundCtx, cancelRound := context.WithCancel(ctx)
+		do.ForAll(round, func(i int) {
+			queryCtx, cancel := context.WithTimeout(roundCtx, options.Timeout)
+			defer cancel()
+			begin := time.Now()
+			candidates, err := node.queryCloserPeersFromTarget(queryCtx, round[i], target)
+			rtt := time.Since(begin)
+			mu.Lock()
+			defer mu.Unlock()
+			if hop != nil {
+				hop(LookupHop{Peer: round[i], CloserPeers: len(candidates), RTT: rtt, Err: err})
+			}
+			if err != nil {
+				// Peers that were cancelled by a hedged round did not fail,
+				// and can be queried again.
+				if roundCtx.Err() != nil && ctx.Err() == nil {
+					delete(queried, round[i].Address())
+					return
+				}
+				if options.Debug >= DebugLow {
+					log.Println(err)
+				}
+				failed[round[i].Address()] = struct{}{}
+				node.lookupCache.invalidate(round[i].Address())
+				return
+			}
+			if options.HedgeFactor > 1 {
+				cancelRound()
+			}
+			for _, candidate := range candidates {
+				if _, ok := seen[candidate.Address()]; ok {
+					continue
+				}
+				seen[candidate.Address()] = struct{}{}
+				closest = append(closest, candidate)
+			}
+		})
+		cancelRound()
+
+		// Peers that failed to respond are not considered to be close.
+		responsive := closest[:0]
+		for _, peer := range closest {
+			if _, ok := failed[peer.Address()]; !ok {
+				responsive = append(responsive, peer)
+			}
+		}
+		closest = responsive
+		sortByDistance(closest, target)
+	}
+	return truncate(closest, k), nil
+}
+
+// ReplicaSet returns the k closest live Nodes to a key, ordered by their
+// distance to the key. These are the Nodes that should store a replica of the
+// value for the key. The network is searched using Lookup, and the closest
+// peers found are pinged to verify that they are live. The Node itself is
+// included if it is one of the k closest Nodes.
+func (node *Node) ReplicaSet(ctx context.Context, key identity.Address, k int) (identity.MultiAddresses, error) {
+	// Look for more than k peers, so that there are spares for peers that are
+	// not live.
+	peers, err := node.Lookup(ctx, key, k+node.Alpha())
+	if err != nil && len(peers) == 0 {
+		return nil, err
+	}
+
+	live := make([]bool, len(peers))
+	do.ForAll(peers, func(i int) {
+		pingCtx, cancel := context.WithTimeout(ctx, node.options().Timeout)
+		defer cancel()
+		live[i] = node.pingTarget(pingCtx, peers[i]) == nil
+	})
+	replicas := identity.MultiAddresses{node.MultiAddress()}
+	for i, peer := range peers {
+		if live[i] {
+			replicas = append(replicas, peer)
+		}
+	}
+	sortByDistance(replicas, key)
+	return truncate(replicas, k), nil
+}
+
+// sortByDistance sorts identity.MultiAddresses by their distance to the
+// target identity.Address, closest first.
+func sortByDistance(multiAddresses identity.MultiAddresses, target identity.Address) {
+	sort.SliceStable(multiAddresses, func(i, j int) bool {
+		closer, err := identity.Closer(multiAddresses[i].Address(), multiAddresses[j].Address(), target)
+		return err == nil && closer
+	})
+}
+
+func truncate(multiAddresses identity.MultiAddresses, n int) identity.MultiAddresses {
+	if len(multiAddresses) > n {
+		return multiAddresses[:n]
+	}
+	return multiAddresses
+}
@@ -0,0 +1,336 @@
+package swarm_test
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-swarm-network"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Lookups", func() {
+
+	var node, peer *swarm.Node
+
+	BeforeEach(func() {
+		nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+		Ω(err).ShouldNot(HaveOccurred())
+		node, peer = nodes[0], nodes[1]
+	})
+
+	Context("when resolving an address", func() {
+
+		It("should resolve its own address", func() {
+			multiAddress, err := node.Resolve(context.Background(), node.Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(multiAddress.String()).Should(Equal(node.MultiAddress().String()))
+		})
+
+		It("should resolve addresses in the DHT without querying the network", func() {
+			Ω(node.DHT.UpdateMultiAddress(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			multiAddress, err := node.Resolve(context.Background(), peer.Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(multiAddress.String()).Should(Equal(peer.MultiAddress().String()))
+		})
+
+		It("should return an error when the address cannot be found", func() {
+			_, err := node.Resolve(context.Background(), peer.Address())
+			Ω(err).Should(Equal(swarm.ErrPeerNotFound))
+		})
+	})
+
+	Context("when looking up an address with its path", func() {
+
+		It("should not make any hops when it has no peers", func() {
+			peers, hops, err := node.LookupWithPath(context.Background(), peer.Address(), 1)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(BeEmpty())
+			Ω(hops).Should(BeEmpty())
+		})
+	})
+
+	Context("when crawling the network", func() {
+
+		It("should record itself when it has no peers", func() {
+			topology, err := node.Crawl(context.Background(), 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(topology).Should(HaveLen(1))
+			Ω(topology).Should(HaveKey(node.Address()))
+		})
+	})
+
+	Context("when hedging lookups", func() {
+
+		It("should end a round as soon as one peer responds", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			node, live, hanging := nodes[0], nodes[1], nodes[2]
+			node.Options.HedgeFactor = 2
+			node.Options.Timeout = time.Second
+			stop, err := ServeNodes([]*swarm.Node{live})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			silent, err := ListenSilently(hanging)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer silent.Close()
+			Ω(node.AddPeer(live.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.AddPeer(hanging.MultiAddress())).ShouldNot(HaveOccurred())
+
+			keyPair, err := identity.NewKeyPair()
+			Ω(err).ShouldNot(HaveOccurred())
+			_, hops, _ := node.LookupWithPath(context.Background(), keyPair.Address(), 2)
+			Ω(len(hops)).Should(BeNumerically(">=", 2))
+			Ω(hops[0].Peer.Address()).Should(Equal(live.Address()))
+			Ω(hops[0].Err).ShouldNot(HaveOccurred())
+
+			// The query to the hanging peer is cancelled, instead of waiting
+			// for the timeout.
+			Ω(hops[1].Peer.Address()).Should(Equal(hanging.Address()))
+			Ω(hops[1].Err).Should(HaveOccurred())
+			Ω(hops[1].RTT).Should(BeNumerically("<", node.Options.Timeout/2))
+		})
+	})
+
+	Context("when caching query responses", func() {
+
+		// query asks the server for the peers that are closer to the target,
+		// and returns their addresses.
+		query := func(client, server *swarm.Node, target identity.Address) []identity.Address {
+			results, err := client.QueryCloserPeersForTargets(context.Background(), server.MultiAddress(), []identity.Address{target})
+			Ω(err).ShouldNot(HaveOccurred())
+			addresses := []identity.Address{}
+			for _, multiAddress := range results[target] {
+				addresses = append(addresses, multiAddress.Address())
+			}
+			return addresses
+		}
+
+		It("should serve the cached response until it expires", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			server, client, target := nodes[0], nodes[1], nodes[2]
+			server.Options.QueryCacheTTL = 300 * time.Millisecond
+			stop, err := ServeNodes([]*swarm.Node{server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			Ω(query(client, server, target.Address())).ShouldNot(ContainElement(target.Address()))
+			Ω(server.AddPeer(target.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(query(client, server, target.Address())).ShouldNot(ContainElement(target.Address()))
+
+			time.Sleep(400 * time.Millisecond)
+			Ω(query(client, server, target.Address())).Should(ContainElement(target.Address()))
+		})
+	})
+
+	Context("when answering queries", func() {
+
+		// query asks the server for the peers that are closer to the target.
+		query := func(client, server *swarm.Node, target identity.Address) identity.MultiAddresses {
+			results, err := client.QueryCloserPeersForTargets(context.Background(), server.MultiAddress(), []identity.Address{target})
+			Ω(err).ShouldNot(HaveOccurred())
+			return results[target]
+		}
+
+		It("should return fallback peers when no peer is closer", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			server, client, other := nodes[0], nodes[1], nodes[2]
+			server.Options.QueryFallbackPeers = 1
+			Ω(server.AddPeer(other.MultiAddress())).ShouldNot(HaveOccurred())
+			stop, err := ServeNodes([]*swarm.Node{server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			// No peer is closer to the server than the server itself.
+			peers := query(client, server, server.Address())
+			Ω(peers).Should(HaveLen(1))
+			Ω(peers[0].Address()).Should(Equal(other.Address()))
+		})
+
+		It("should return at most the maximum number of results", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 6, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			keyPair, err := identity.NewKeyPair()
+			Ω(err).ShouldNot(HaveOccurred())
+			target := keyPair.Address()
+			SortNodesByDistance(nodes, target)
+
+			// Every peer of the server is closer to the target than it is.
+			client, server := nodes[4], nodes[5]
+			server.Options.MaxQueryResults = 2
+			for _, node := range nodes[:4] {
+				Ω(server.AddPeer(node.MultiAddress())).ShouldNot(HaveOccurred())
+			}
+			stop, err := ServeNodes([]*swarm.Node{server})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			peers := query(client, server, target)
+			Ω(peers).Should(HaveLen(2))
+			Ω(peers[0].Address()).Should(Equal(nodes[0].Address()))
+			Ω(peers[1].Address()).Should(Equal(nodes[1].Address()))
+		})
+	})
+
+	Context("when caching lookups", func() {
+
+		// setupLookup returns a client with a lookup cache and a served peer
+		// in its DHT, and the lookup of a target through that peer.
+		setupLookup := func() (*swarm.Node, *swarm.Node, identity.Address, func()) {
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			client, server := nodes[0], nodes[1]
+			client.Options.LookupCacheTTL = 300 * time.Millisecond
+			client.Options.Timeout = time.Second
+			Ω(client.AddPeer(server.MultiAddress())).ShouldNot(HaveOccurred())
+			stop, err := ServeNodes([]*swarm.Node{server})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			keyPair, err := identity.NewKeyPair()
+			Ω(err).ShouldNot(HaveOccurred())
+			peers, err := client.Lookup(context.Background(), keyPair.Address(), 1)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(HaveLen(1))
+			return client, server, keyPair.Address(), stop
+		}
+
+		It("should serve the cached result within its TTL", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			client, server, target, stop := setupLookup()
+			stop()
+
+			peers, err := client.Lookup(context.Background(), target, 1)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(HaveLen(1))
+			Ω(peers[0].Address()).Should(Equal(server.Address()))
+		})
+
+		It("should traverse the network once the result expires", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			client, _, target, stop := setupLookup()
+			stop()
+
+			time.Sleep(400 * time.Millisecond)
+			peers, _ := client.Lookup(context.Background(), target, 1)
+			Ω(peers).Should(BeEmpty())
+		})
+
+		It("should drop the cached result when a peer in it is removed", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			client, server, target, stop := setupLookup()
+			stop()
+
+			// The ping to the stopped server must fail quickly.
+			client.Options.OutboundMiddleware = func(ctx context.Context, method string, target identity.MultiAddress) context.Context {
+				ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+				time.AfterFunc(200*time.Millisecond, cancel)
+				return ctx
+			}
+			pruned, err := client.Prune(server.Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pruned).Should(BeTrue())
+			peers, err := client.Lookup(context.Background(), target, 1)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(peers).Should(BeEmpty())
+		})
+	})
+
+	Context("when querying a batch of targets", func() {
+
+		It("should return an error when the batch is too large", func() {
+			node.Options.MaxBatchTargets = 1
+			_, err := node.QueryCloserPeersForTargets(context.Background(), peer.MultiAddress(), []identity.Address{node.Address(), peer.Address()})
+			Ω(err).Should(Equal(swarm.ErrBatchTooLarge))
+		})
+
+		It("should query each target once", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			stop, err := ServeNodes([]*swarm.Node{peer})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			calls := 0
+			mu := new(sync.Mutex)
+			node.Options.OutboundMiddleware = func(ctx context.Context, method string, target identity.MultiAddress) context.Context {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				return ctx
+			}
+
+			targets := make([]identity.Address, 8)
+			for i := range targets {
+				targets[i] = node.RandomAddress(i)
+			}
+			results, err := node.QueryCloserPeersForTargets(context.Background(), peer.MultiAddress(), targets)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(results).Should(HaveLen(len(targets)))
+			for _, target := range targets {
+				Ω(results).Should(HaveKey(target))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			Ω(calls).Should(Equal(len(targets)))
+		})
+
+		It("should query the targets one at a time when alpha is zero", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			stop, err := ServeNodes([]*swarm.Node{peer})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			node.Options.Alpha = 0
+
+			targets := []identity.Address{node.RandomAddress(0), node.RandomAddress(1)}
+			results, err := node.QueryCloserPeersForTargets(context.Background(), peer.MultiAddress(), targets)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(results).Should(HaveLen(len(targets)))
+		})
+
+		It("should stop querying when the context is cancelled", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			peer.Delegate = &slowDelegate{mockDelegate: newMockDelegate(), delay: 300 * time.Millisecond}
+			stop, err := ServeNodes([]*swarm.Node{peer})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			node.Options.Alpha = 1
+
+			targets := make([]identity.Address, 8)
+			for i := range targets {
+				targets[i] = node.RandomAddress(i)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			begin := time.Now()
+			_, err = node.QueryCloserPeersForTargets(ctx, peer.MultiAddress(), targets)
+			Ω(err).Should(HaveOccurred())
+			Ω(time.Since(begin)).Should(BeNumerically("<", time.Second))
+		})
+	})
+})
@@ -0,0 +1,178 @@
+package swarm
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+)
+
+// PauseMaintenance stops the Node from starting background maintenance until
+// ResumeMaintenance is called. While paused, the Node does not bootstrap
+// again, retry pending peers, promote replacements, or ping new peers that
+// are on probation, which are dropped instead. RPCs are still served, and
+// calls made by the application, such as Lookup and PruneAll, are not
+// affected. Maintenance that is already running is allowed to finish.
+func (node *Node) PauseMaintenance() {
+	node.stateMu.Lock()
+	defer node.stateMu.Unlock()
+	node.paused = true
+}
+
+// ResumeMaintenance restarts background maintenance after PauseMaintenance.
+func (node *Node) ResumeMaintenance() {
+	node.stateMu.Lock()
+	defer node.stateMu.Unlock()
+	node.paused = false
+}
+
+// MaintenancePaused returns true if background maintenance is paused,
+// otherwise false.
+func (node *Node) MaintenancePaused() bool {
+	node.stateMu.RLock()
+	defer node.stateMu.RUnlock()
+	return node.paused
+}
+
+// restartAutoReBootstrapUnsafe stops the autoReBootstrap goroutine, if there
+// is one, and starts a new one if the interval is positive. It assumes that
+// the optionsMu is held.
+func (node *Node) restartAutoReBootstrapUnsafe(interval time.Duration) {
+	if node.watchdog != nil {
+		close(node.watchdog)
+		node.watchdog = nil
+	}
+	if interval > 0 {
+		node.watchdog = make(chan struct{})
+		go node.autoReBootstrap(interval, node.watchdog)
+	}
+}
+
+// autoReBootstrap bootstraps the Node again when the number of peers in the
+// dht.DHT has been below the MinPeers option for longer than the interval. It
+// runs until the Node is closed, or until the stop channel is closed.
+func (node *Node) autoReBootstrap(interval time.Duration, stop <-chan struct{}) {
+	period := interval / 4
+	if period <= 0 {
+		period = interval
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	var belowSince time.Time
+	for {
+		select {
+		case <-node.done:
+			return
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if node.MaintenancePaused() {
+				continue
+			}
+			options := node.options()
+			minPeers := options.MinPeers
+			if minPeers < 1 {
+				minPeers = 1
+			}
+			if len(node.DHT.MultiAddresses()) >= minPeers {
+				belowSince = time.Time{}
+				continue
+			}
+			if belowSince.IsZero() {
+				belowSince = now
+				continue
+			}
+			if now.Sub(belowSince) < interval {
+				continue
+			}
+			if options.Debug >= DebugLow {
+				log.Printf("%v has had less than %v peers for %v, bootstrapping again...\n", node.Address(), minPeers, now.Sub(belowSince))
+			}
+			if err := node.Bootstrap(); err != nil && err != ErrBootstrapInProgress && options.Debug >= DebugLow {
+				log.Println(err)
+			}
+			belowSince = time.Time{}
+		}
+	}
+}
+
+// PingResult is the result of pinging an identity.MultiAddress. The RTT is
+// only meaningful when the Err is nil, and is close to zero when the result
+// was reused because of the MinPingInterval option.
+type PingResult struct {
+	MultiAddress identity.MultiAddress
+	RTT          time.Duration
+	Err          error
+}
+
+// PingTargets pings the identity.MultiAddresses, with at most Alpha pings at
+// the same time, and streams a PingResult as each ping completes. The channel
+// is closed when all identity.MultiAddresses have been pinged, or the context
+// is done. The caller must read from the channel until it is closed, or cancel
+// the context.
+func (node *Node) PingTargets(ctx context.Context, targets identity.MultiAddresses) <-chan PingResult {
+	results := make(chan PingResult)
+	go func() {
+		defer close(results)
+
+		concurrency := node.Alpha()
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		semaphore := make(chan struct{}, concurrency)
+
+		wg := new(sync.WaitGroup)
+		defer wg.Wait()
+		for _, target := range targets {
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func(target identity.MultiAddress) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+
+				pingCtx, cancel := context.WithTimeout(ctx, node.options().Timeout)
+				defer cancel()
+				start := time.Now()
+				err := node.pingTarget(pingCtx, target)
+				result := PingResult{MultiAddress: target, RTT: time.Since(start), Err: err}
+				select {
+				case results <- result:
+				case <-ctx.Done():
+				}
+			}(target)
+		}
+	}()
+	return results
+}
+
+// PruneAll pings every peer in the dht.DHT and removes the peers that do not
+// respond. At most Alpha peers are pinged at the same time. Returns the number
+// of peers that were removed. If the context is done, peers that have not
+// been pinged are kept, and the error of the context is returned.
+func (node *Node) PruneAll(ctx context.Context) (int, error) {
+	removed := 0
+	var firstErr error
+	for result := range node.PingTargets(WithPriority(ctx, PriorityLow), node.DHT.MultiAddresses()) {
+		if result.Err == nil || ctx.Err() != nil {
+			continue
+		}
+		ok, err := node.removeMultiAddress(result.MultiAddress)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if ok {
+			removed++
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return removed, err
+	}
+	return removed, firstErr
+}
@@ -0,0 +1,226 @@
+package swarm
+
+import (
+	"strconv"
+
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// NeighborhoodMetadataKey is the gRPC metadata that a client sends with a
+// frontier query to subscribe to the neighborhood of the target, instead of
+// running a traversal. Its value is the number of peers in the neighborhood.
+// The Node acknowledges the subscription with the same key in its gRPC header,
+// and then sends each neighborhood as a snapshot of peers that is terminated
+// by an empty rpc.MultiAddress.
+const NeighborhoodMetadataKey = "swarm-neighborhood"
+
+// A NeighborhoodEvent is emitted when an identity.MultiAddress is added to, or
+// removed from, the neighborhood of a target identity.Address.
+type NeighborhoodEvent struct {
+	MultiAddress identity.MultiAddress
+	Removed      bool
+}
+
+// SubscribeNeighborhood returns a channel of NeighborhoodEvents for the n
+// closest peers to the target identity.Address. The current neighborhood is
+// sent first, as a NeighborhoodEvent for each peer, and afterwards a
+// NeighborhoodEvent is sent whenever a change to the dht.DHT changes the
+// neighborhood. The channel is closed when the context is done, or when the
+// Node is closed.
+func (node *Node) SubscribeNeighborhood(ctx context.Context, target identity.Address, n int) <-chan NeighborhoodEvent {
+	events := make(chan NeighborhoodEvent)
+	go func() {
+		defer close(events)
+
+		neighborhood := map[identity.Address]identity.MultiAddress{}
+		node.watchNeighborhood(ctx, target, n, func(neighbors identity.MultiAddresses) bool {
+			var changes []NeighborhoodEvent
+			changes, neighborhood = diffNeighborhood(neighborhood, neighbors)
+			for _, event := range changes {
+				if !node.sendNeighborhoodEvent(ctx, events, event) {
+					return false
+				}
+			}
+			return true
+		})
+	}()
+	return events
+}
+
+// SubscribePeerNeighborhood is the same as SubscribeNeighborhood, but the
+// neighborhood is the one in the dht.DHT of a peer. The channel is closed when
+// the context is done, or when the stream from the peer ends. Returns
+// ErrNeighborhoodUnavailable if the peer does not support subscriptions.
+func (node *Node) SubscribePeerNeighborhood(ctx context.Context, peer identity.MultiAddress, target identity.Address, n int) (<-chan NeighborhoodEvent, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Join(md, metadata.Pairs(NeighborhoodMetadataKey, strconv.Itoa(n))))
+	ctx = node.outbound(ctx, MethodQueryCloserPeersOnFrontier, peer)
+	if err := node.schedule(ctx); err != nil {
+		return nil, err
+	}
+	conn, err := node.dial(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := rpc.NewSwarmNodeClient(conn).QueryCloserPeersOnFrontier(ctx, node.query(target))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	header, err := stream.Header()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if len(header[NeighborhoodMetadataKey]) == 0 {
+		conn.Close()
+		return nil, ErrNeighborhoodUnavailable
+	}
+
+	events := make(chan NeighborhoodEvent)
+	go func() {
+		defer close(events)
+		defer conn.Close()
+
+		neighborhood := map[identity.Address]identity.MultiAddress{}
+		snapshot := identity.MultiAddresses{}
+		for {
+			serialized, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			if serialized.Multi != "" {
+				multiAddress, err := deserializeMultiAddress(serialized)
+				if err != nil {
+					return
+				}
+				snapshot = append(snapshot, multiAddress)
+				continue
+			}
+
+			var changes []NeighborhoodEvent
+			changes, neighborhood = diffNeighborhood(neighborhood, snapshot)
+			for _, event := range changes {
+				if !node.sendNeighborhoodEvent(ctx, events, event) {
+					return
+				}
+			}
+			snapshot = identity.MultiAddresses{}
+		}
+	}()
+	return events, nil
+}
+
+// streamNeighborhood sends the neighborhood of the target of a frontier query
+// to a subscriber, until the subscriber goes away or the Node is closed.
+func (node *Node) streamNeighborhood(query *rpc.Query, stream rpc.SwarmNode_QueryCloserPeersOnFrontierServer, n int) error {
+	if err := stream.SendHeader(metadata.Pairs(NeighborhoodMetadataKey, "true")); err != nil {
+		return err
+	}
+
+	var sendErr error
+	first := true
+	neighborhood := map[identity.Address]identity.MultiAddress{}
+	err := node.watchNeighborhood(stream.Context(), identity.Address(query.Query.Address), n, func(neighbors identity.MultiAddresses) bool {
+		var changes []NeighborhoodEvent
+		changes, neighborhood = diffNeighborhood(neighborhood, neighbors)
+		if len(changes) == 0 && !first {
+			return true
+		}
+		first = false
+		for _, neighbor := range neighbors {
+			if sendErr = node.sendMultiAddress(stream, neighbor); sendErr != nil {
+				return false
+			}
+		}
+		if sendErr = stream.Send(&rpc.MultiAddress{}); sendErr != nil {
+			sendErr = ErrClientDisconnected
+			return false
+		}
+		return true
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+// watchNeighborhood calls the function with the n closest peers to the target
+// identity.Address, and again whenever the dht.DHT changes, until the function
+// returns false, the context is done, or the Node is closed.
+func (node *Node) watchNeighborhood(ctx context.Context, target identity.Address, n int, f func(identity.MultiAddresses) bool) error {
+	for {
+		node.dhtMu.RLock()
+		neighbors, err := node.findMultiAddressNeighborsUnsafe(target, n)
+		changed := node.dhtChanged
+		node.dhtMu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if !f(neighbors) {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-node.done:
+			return nil
+		}
+	}
+}
+
+// diffNeighborhood returns the NeighborhoodEvents that change the previous
+// neighborhood into the neighbors, and the new neighborhood. Removals are
+// returned before additions.
+func diffNeighborhood(neighborhood map[identity.Address]identity.MultiAddress, neighbors identity.MultiAddresses) ([]NeighborhoodEvent, map[identity.Address]identity.MultiAddress) {
+	events := []NeighborhoodEvent{}
+	current := make(map[identity.Address]identity.MultiAddress, len(neighbors))
+	for _, neighbor := range neighbors {
+		current[neighbor.Address()] = neighbor
+	}
+	for address, multiAddress := range neighborhood {
+		if _, ok := current[address]; !ok {
+			events = append(events, NeighborhoodEvent{MultiAddress: multiAddress, Removed: true})
+		}
+	}
+	for _, neighbor := range neighbors {
+		if _, ok := neighborhood[neighbor.Address()]; !ok {
+			events = append(events, NeighborhoodEvent{MultiAddress: neighbor})
+		}
+	}
+	return events, current
+}
+
+// wantsNeighborhood returns the size of the neighborhood that the client of a
+// frontier query subscribed to, and false if it did not subscribe.
+func wantsNeighborhood(ctx context.Context) (int, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[NeighborhoodMetadataKey]) == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(md[NeighborhoodMetadataKey][0])
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// sendNeighborhoodEvent sends a NeighborhoodEvent to a subscriber. Returns
+// false if the context is done, or the Node is closed, before the
+// NeighborhoodEvent could be sent.
+func (node *Node) sendNeighborhoodEvent(ctx context.Context, events chan<- NeighborhoodEvent, event NeighborhoodEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-node.done:
+		return false
+	}
+}
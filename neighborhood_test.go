@@ -0,0 +1,106 @@
+package swarm_test
+
+import (
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-swarm-network"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Neighborhood subscriptions", func() {
+
+	var node, peer *swarm.Node
+
+	BeforeEach(func() {
+		nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+		Ω(err).ShouldNot(HaveOccurred())
+		node, peer = nodes[0], nodes[1]
+	})
+
+	Context("when the neighborhood changes", func() {
+
+		It("should send an event when a peer is added", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := node.SubscribeNeighborhood(ctx, peer.Address(), 1)
+
+			Ω(node.UpdateMultiAddresses(identity.MultiAddresses{peer.MultiAddress()})[0]).ShouldNot(HaveOccurred())
+			var event swarm.NeighborhoodEvent
+			Eventually(events).Should(Receive(&event))
+			Ω(event.MultiAddress.String()).Should(Equal(peer.MultiAddress().String()))
+			Ω(event.Removed).Should(BeFalse())
+		})
+
+		It("should start with the closest peers from every bucket", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 32, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			multiAddresses := identity.MultiAddresses{}
+			for _, node := range nodes[2:] {
+				multiAddresses = append(multiAddresses, node.MultiAddress())
+			}
+			nodes[0].UpdateMultiAddresses(multiAddresses)
+
+			target := nodes[1].Address()
+			expected := nodes[0].DHT.MultiAddresses()
+			sort.SliceStable(expected, func(i, j int) bool {
+				closer, err := identity.Closer(expected[i].Address(), expected[j].Address(), target)
+				return err == nil && closer
+			})
+			expectedAddresses := []identity.Address{}
+			for _, multiAddress := range expected[:4] {
+				expectedAddresses = append(expectedAddresses, multiAddress.Address())
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := nodes[0].SubscribeNeighborhood(ctx, target, 4)
+			addresses := []identity.Address{}
+			for i := 0; i < 4; i++ {
+				var event swarm.NeighborhoodEvent
+				Eventually(events).Should(Receive(&event))
+				addresses = append(addresses, event.MultiAddress.Address())
+			}
+			Ω(addresses).Should(ConsistOf(expectedAddresses))
+		})
+
+		It("should close the channel when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			events := node.SubscribeNeighborhood(ctx, peer.Address(), 1)
+			cancel()
+			Eventually(events).Should(BeClosed())
+		})
+	})
+
+	Context("when subscribing to the neighborhood of a peer", func() {
+
+		It("should send an event when the peer adds a peer", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm+2, 1, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			other := nodes[0]
+			stop, err := ServeNodes([]*swarm.Node{peer})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events, err := node.SubscribePeerNeighborhood(ctx, peer.MultiAddress(), other.Address(), 1)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(peer.AddPeer(other.MultiAddress())).ShouldNot(HaveOccurred())
+			var event swarm.NeighborhoodEvent
+			Eventually(events, 3*time.Second).Should(Receive(&event))
+			Ω(event.MultiAddress.String()).Should(Equal(other.MultiAddress().String()))
+			Ω(event.Removed).Should(BeFalse())
+
+			cancel()
+			Eventually(events, 3*time.Second).Should(BeClosed())
+		})
+	})
+})
@@ -2,6 +2,9 @@ package swarm
 
 import (
 	"log"
+	"math"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/republicprotocol/go-dht"
@@ -13,7 +16,8 @@ import (
 )
 
 // The Delegate is used as a callback interface to inject logic into the
-// different RPCs.
+// different RPCs. A Node can be created with a nil Delegate, in which case no
+// callbacks are made.
 type Delegate interface {
 	OnPingReceived(from identity.MultiAddress)
 	OnQueryCloserPeersReceived(from identity.MultiAddress)
@@ -22,22 +26,112 @@ type Delegate interface {
 
 // Node implements the gRPC Node service.
 type Node struct {
+	// resourceErrors is accessed atomically, so it is the first field to
+	// guarantee 64-bit alignment on 32-bit platforms.
+	resourceErrors uint64
+
 	Delegate
 	Server  *grpc.Server
 	DHT     *dht.DHT
 	Options Options
+
+	optionsMu  *sync.RWMutex
+	watchdog   chan struct{}
+	dhtMu      *sync.RWMutex
+	dhtChanged chan struct{}
+	sequence   uint64
+	peersMu    *sync.RWMutex
+	peers      map[identity.Address]*peer
+
+	// bucketAddresses has an identity.Address in each bucket of the dht.DHT,
+	// indexed by the number of prefix bits that it shares with the Node.
+	bucketAddresses []identity.Address
+
+	stateMu          *sync.RWMutex
+	started          time.Time
+	bootstrapStarted time.Time
+	convergenceTime  time.Duration
+	bootstrapped     bool
+	bootstrapping    bool
+	lastBootstrap    *BootstrapResult
+	draining         bool
+	paused           bool
+	closed           bool
+	done             chan struct{}
+
+	randomMu *sync.Mutex
+	random   *rand.Rand
+
+	queryCache   *queryCache
+	lookupCache  *lookupCache
+	pingCache    *pingCache
+	scheduler    *outboundScheduler
+	replacements *replacementCache
+	quarantine   *quarantine
+	probes       *reachabilityProbes
+	pending      *pendingPeers
+	values       ValueStore
 }
 
 // NewNode returns a Node with the given its own identity.MultiAddress, a list
 // of bootstrap node identity.MultiAddresses, and a delegate that defines
-// callbacks for each RPC.
+// callbacks for each RPC. It panics if the Options are not valid, so Options
+// that come from user input should be passed to NewNodeWithError instead.
 func NewNode(server *grpc.Server, delegate Delegate, options Options) *Node {
-	return &Node{
+	node, err := NewNodeWithError(server, delegate, options)
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+// NewNodeWithError is the same as NewNode, but it returns the error from
+// validating the Options instead of panicking.
+func NewNodeWithError(server *grpc.Server, delegate Delegate, options Options) (*Node, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	randomSource := options.RandomSource
+	if randomSource == nil {
+		randomSource = rand.NewSource(time.Now().UnixNano())
+	}
+	values := options.ValueStore
+	if values == nil {
+		values = NewMemoryValueStore()
+	}
+	node := &Node{
 		Delegate: delegate,
 		Server:   server,
 		DHT:      dht.NewDHT(options.MultiAddress.Address(), options.MaxBucketLength),
 		Options:  options,
-	}
+
+		optionsMu:  new(sync.RWMutex),
+		dhtMu:      new(sync.RWMutex),
+		dhtChanged: make(chan struct{}),
+		peersMu:    new(sync.RWMutex),
+		peers:      map[identity.Address]*peer{},
+
+		bucketAddresses: newBucketAddresses(options.MultiAddress.Address()),
+
+		stateMu: new(sync.RWMutex),
+		started: time.Now(),
+		done:    make(chan struct{}),
+
+		randomMu: new(sync.Mutex),
+		random:   rand.New(randomSource),
+
+		queryCache:   newQueryCache(),
+		lookupCache:  newLookupCache(),
+		pingCache:    newPingCache(),
+		scheduler:    newOutboundScheduler(),
+		replacements: newReplacementCache(),
+		quarantine:   newQuarantine(),
+		probes:       newReachabilityProbes(),
+		pending:      newPendingPeers(),
+		values:       values,
+	}
+	node.restartAutoReBootstrapUnsafe(options.AutoReBootstrap)
+	return node, nil
 }
 
 // Register the gRPC service.
@@ -47,77 +141,177 @@ func (node *Node) Register() {
 
 // Bootstrap the Node into the network. The Node will connect to each bootstrap
 // Node and attempt to find itself in the network. This process will ultimately
-// connect it to Nodes that are close to it in XOR space.
-func (node *Node) Bootstrap() {
-	if node.Options.Debug >= DebugMedium {
+// connect it to Nodes that are close to it in XOR space. Bootstrap Nodes with
+// a higher priority are used first. Returns ErrBootstrapFailed if none of the
+// bootstrap Nodes could be queried, and ErrBootstrapInProgress, without doing
+// anything, if the Node is already bootstrapping. When the RequireNonSeedPeers
+// option is set, ErrOnlySeedPeers is returned if the Node did not find any
+// peers other than the bootstrap Nodes.
+func (node *Node) Bootstrap() error {
+	options := node.options()
+	if options.Debug >= DebugMedium {
 		log.Printf("%v is bootstrapping...\n", node.Address())
 	}
+	node.stateMu.Lock()
+	if node.bootstrapping {
+		node.stateMu.Unlock()
+		return ErrBootstrapInProgress
+	}
+	node.bootstrapping = true
+	if node.bootstrapStarted.IsZero() {
+		node.bootstrapStarted = time.Now()
+	}
+	node.stateMu.Unlock()
+	started := time.Now()
+	results := newSeedResults()
+	defer func() {
+		node.stateMu.Lock()
+		node.bootstrapping = false
+		node.lastBootstrap = &BootstrapResult{Started: started, Finished: time.Now(), Seeds: results.seeds}
+		node.stateMu.Unlock()
+	}()
+
 	// Add all bootstrap Nodes to the DHT.
-	for _, bootstrapMultiAddress := range node.Options.BootstrapMultiAddresses {
-		err := node.DHT.UpdateMultiAddress(bootstrapMultiAddress)
-		if err != nil && node.Options.Debug >= DebugLow {
+	tiers := node.bootstrapTiers()
+	bootstrapMultiAddresses := identity.MultiAddresses{}
+	for _, tier := range tiers {
+		bootstrapMultiAddresses = append(bootstrapMultiAddresses, tier...)
+	}
+	for _, bootstrapMultiAddress := range bootstrapMultiAddresses {
+		err := node.updateMultiAddress(bootstrapMultiAddress)
+		if err != nil && options.Debug >= DebugLow {
 			log.Println(err)
 		}
 	}
-	if node.Options.Concurrent {
+	var succeeded bool
+	if options.Concurrent {
 		// Concurrently search all bootstrap Nodes for itself.
-		do.ForAll(node.Options.BootstrapMultiAddresses, func(i int) {
-			node.bootstrapUsingMultiAddress(node.Options.BootstrapMultiAddresses[i])
-		})
+		succeeded = node.bootstrapConcurrently(tiers, results)
 	} else {
 		// Sequentially search all bootstrap Nodes for itself.
-		for _, bootstrapMultiAddress := range node.Options.BootstrapMultiAddresses {
-			node.bootstrapUsingMultiAddress(bootstrapMultiAddress)
-		}
+		succeeded = node.bootstrapSequentially(tiers, results)
 	}
-	if node.Options.Debug >= DebugMedium {
+	if options.BootstrapLookupTargets == BootstrapLookupSelfAndRandomPerBucket {
+		node.bootstrapRandomTargets(bootstrapMultiAddresses)
+	}
+	if options.Debug >= DebugMedium {
 		log.Printf("%v connected to %v peers after bootstrapping.\n", node.Address(), len(node.DHT.MultiAddresses()))
 	}
-	if node.Options.Debug >= DebugHigh {
+	if options.Debug >= DebugHigh {
 		log.Printf("%v is now connected to:\n", node.Address())
 		for _, multiAddress := range node.DHT.MultiAddresses() {
 			log.Printf("  %v\n", multiAddress)
 		}
 	}
+	if succeeded && options.RequireNonSeedPeers && node.onlySeedPeers(bootstrapMultiAddresses) {
+		return ErrOnlySeedPeers
+	}
+	if succeeded && options.MinBucketsCovered > 0 {
+		if err := node.bootstrapCoverage(); err != nil {
+			return err
+		}
+	}
+	if succeeded {
+		node.stateMu.Lock()
+		node.bootstrapped = true
+		node.stateMu.Unlock()
+		return nil
+	}
+	if len(bootstrapMultiAddresses) > 0 {
+		return ErrBootstrapFailed
+	}
+	return nil
+}
+
+// onlySeedPeers returns true if every peer in the dht.DHT is one of the
+// bootstrap identity.MultiAddresses.
+func (node *Node) onlySeedPeers(bootstrapMultiAddresses identity.MultiAddresses) bool {
+	seeds := make(map[identity.Address]struct{}, len(bootstrapMultiAddresses))
+	for _, bootstrapMultiAddress := range bootstrapMultiAddresses {
+		seeds[bootstrapMultiAddress.Address()] = struct{}{}
+	}
+	for _, multiAddress := range node.DHT.MultiAddresses() {
+		if _, ok := seeds[multiAddress.Address()]; !ok {
+			return false
+		}
+	}
+	return true
 }
 
-// Prune an identity.Address from the dht.DHT. Returns a boolean indicating
-// whether or not an identity.Address was pruned.
+// Prune an identity.Address from the dht.DHT. The oldest identity.MultiAddress
+// in the bucket of the identity.Address is pinged and, if it does not respond
+// and is still the oldest identity.MultiAddress in the bucket, it is removed.
+// Returns a boolean indicating whether or not an identity.Address was pruned.
 func (node *Node) Prune(target identity.Address) (bool, error) {
-	bucket, err := node.DHT.FindBucket(target)
-	if err != nil {
+	multiAddress, err := node.oldestMultiAddress(target)
+	if err != nil || multiAddress == nil {
 		return false, err
 	}
-	if bucket == nil || bucket.Length() == 0 {
-		return false, nil
-	}
-	multiAddress := bucket.MultiAddresses[0]
-	if err := rpc.PingTarget(multiAddress, node.MultiAddress(), time.Minute); err != nil {
-		return true, node.DHT.RemoveMultiAddress(multiAddress)
+	ctx, cancel := context.WithTimeout(WithPriority(context.Background(), PriorityLow), time.Minute)
+	defer cancel()
+	if err := node.pingTarget(ctx, *multiAddress); err != nil {
+		return node.removeOldestMultiAddress(target, *multiAddress)
 	}
-	return false, node.DHT.UpdateMultiAddress(multiAddress)
+	return false, node.refreshMultiAddress(*multiAddress)
 }
 
 // Address returns the identity.Address of the Node.
 func (node *Node) Address() identity.Address {
-	return node.Options.MultiAddress.Address()
+	return node.MultiAddress().Address()
 }
 
 // MultiAddress returns the identity.MultiAddress of the Node.
 func (node *Node) MultiAddress() identity.MultiAddress {
+	node.optionsMu.RLock()
+	defer node.optionsMu.RUnlock()
 	return node.Options.MultiAddress
 }
 
+// Alpha returns the number of peers that are used by queries and lookups. If
+// the AdaptiveAlpha option is set, this is the base two logarithm of the
+// number of peers in the dht.DHT, clamped between the MinAlpha and MaxAlpha
+// options, and at least one. Otherwise, the Alpha option is returned.
+func (node *Node) Alpha() int {
+	options := node.options()
+	if !options.AdaptiveAlpha {
+		return options.Alpha
+	}
+	alpha := 0
+	if numberOfPeers := len(node.DHT.MultiAddresses()); numberOfPeers > 1 {
+		alpha = int(math.Ceil(math.Log2(float64(numberOfPeers))))
+	}
+	if alpha < options.MinAlpha {
+		alpha = options.MinAlpha
+	}
+	if options.MaxAlpha > 0 && alpha > options.MaxAlpha {
+		alpha = options.MaxAlpha
+	}
+	if alpha < 1 {
+		alpha = 1
+	}
+	return alpha
+}
+
 // Ping is used to test the connection to the Node and exchange
 // identity.MultiAddresses. If the Node does not respond, or it responds with
 // an error, then the connection should be considered unhealthy.
 func (node *Node) Ping(ctx context.Context, from *rpc.MultiAddress) (*rpc.Nothing, error) {
-	if node.Options.Debug >= DebugHigh {
+	if node.options().Debug >= DebugHigh {
 		log.Printf("%v was pinged by %v\n", node.Address(), from.Multi)
 	}
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	ctx, cancel := node.handlerContext(ctx)
+	defer cancel()
+	if header := node.drainingHeader(); header != nil {
+		grpc.SetHeader(ctx, header)
+	}
+	setObservedAddressHeader(ctx)
+	grpc.SetHeader(ctx, node.versionHeader())
+	if header := node.statsHeader(); header != nil {
+		grpc.SetHeader(ctx, header)
+	}
 
 	wait := do.Process(func() do.Option {
 		nothing, err := node.ping(from)
@@ -130,9 +324,9 @@ func (node *Node) Ping(ctx context.Context, from *rpc.MultiAddress) (*rpc.Nothin
 	select {
 	case val := <-wait:
 		if nothing, ok := val.Ok.(*rpc.Nothing); ok {
-			return nothing, val.Err
+			return nothing, rpcError(val.Err)
 		}
-		return &rpc.Nothing{}, val.Err
+		return &rpc.Nothing{}, rpcError(val.Err)
 
 	case <-ctx.Done():
 		return &rpc.Nothing{}, ctx.Err()
@@ -145,15 +339,25 @@ func (node *Node) Ping(ctx context.Context, from *rpc.MultiAddress) (*rpc.Nothin
 // returned are not guaranteed to provide healthy connections and should be
 // pinged.
 func (node *Node) QueryCloserPeers(ctx context.Context, query *rpc.Query) (*rpc.MultiAddresses, error) {
-	if node.Options.Debug >= DebugHigh {
+	if node.options().Debug >= DebugHigh {
 		log.Printf("%v was queried by %v\n", node.Address(), query.From.Multi)
 	}
 	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
+	ctx, cancel := node.handlerContext(ctx)
+	defer cancel()
+	if header := node.drainingHeader(); header != nil {
+		grpc.SetHeader(ctx, header)
+	}
+
+	origin, err := node.origin(ctx)
+	if err != nil {
+		return &rpc.MultiAddresses{Multis: []*rpc.MultiAddress{}}, rpcError(err)
+	}
 
 	wait := do.Process(func() do.Option {
-		peers, err := node.queryCloserPeers(query)
+		peers, err := node.queryCloserPeers(query, origin)
 		if err != nil {
 			return do.Err(err)
 		}
@@ -163,9 +367,15 @@ func (node *Node) QueryCloserPeers(ctx context.Context, query *rpc.Query) (*rpc.
 	select {
 	case val := <-wait:
 		if multiAddresses, ok := val.Ok.(*rpc.MultiAddresses); ok {
-			return multiAddresses, val.Err
+			if header := node.peerAgesHeader(multiAddresses); header != nil {
+				grpc.SetHeader(ctx, header)
+			}
+			if header := node.exhaustedHeader(); header != nil {
+				grpc.SetHeader(ctx, header)
+			}
+			return multiAddresses, rpcError(val.Err)
 		}
-		return &rpc.MultiAddresses{Multis: []*rpc.MultiAddress{}}, val.Err
+		return &rpc.MultiAddresses{Multis: []*rpc.MultiAddress{}}, rpcError(val.Err)
 
 	case <-ctx.Done():
 		return &rpc.MultiAddresses{Multis: []*rpc.MultiAddress{}}, ctx.Err()
@@ -178,213 +388,499 @@ func (node *Node) QueryCloserPeers(ctx context.Context, query *rpc.Query) (*rpc.
 // Node itself. The rpc.MultiAddresses returned are not guaranteed to provide
 // healthy connections and should be pinged.
 func (node *Node) QueryCloserPeersOnFrontier(query *rpc.Query, stream rpc.SwarmNode_QueryCloserPeersOnFrontierServer) error {
-	if node.Options.Debug >= DebugHigh {
+	if node.options().Debug >= DebugHigh {
 		log.Printf("%v was frontier queried by %v\n", node.Address(), query.From.Multi)
 	}
 	if err := stream.Context().Err(); err != nil {
 		return err
 	}
+	if header := node.drainingHeader(); header != nil {
+		stream.SetHeader(header)
+	}
+	// Subscriptions last until the client goes away, so they do not use the
+	// default handler timeout.
+	if n, ok := wantsNeighborhood(stream.Context()); ok {
+		return rpcError(node.streamNeighborhood(query, stream, n))
+	}
+	ctx, cancel := node.handlerContext(stream.Context())
+	defer cancel()
 
+	stats := FrontierStats{}
+	begin := time.Now()
 	wait := do.Process(func() do.Option {
-		return do.Err(node.queryCloserPeersOnFrontier(query, stream))
+		return do.Err(node.queryCloserPeersOnFrontier(ctx, query, stream, &stats))
 	})
 
-	for {
-		select {
-		case val := <-wait:
-			return val.Err
-
-		case <-stream.Context().Done():
-			return stream.Context().Err()
+	select {
+	case val := <-wait:
+		if wantsFrontierStats(stream.Context()) {
+			stats.Elapsed = time.Since(begin)
+			stream.SetTrailer(stats.trailer())
 		}
+		return rpcError(val.Err)
+
+	case <-ctx.Done():
+		// The traversal sends on the stream, so it must stop before the
+		// handler returns. It stops soon after the context is done.
+		<-wait
+		return ctx.Err()
+	}
+}
+
+// handlerContext returns a context with the DefaultHandlerTimeout option as
+// its deadline, when the context of an RPC has no deadline. This prevents
+// clients that do not set a deadline from running an RPC forever.
+func (node *Node) handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	options := node.options()
+	if _, ok := ctx.Deadline(); ok || options.DefaultHandlerTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	if options.Debug >= DebugHigh {
+		log.Printf("%v is using a default deadline of %v\n", node.Address(), options.DefaultHandlerTimeout)
 	}
+	return context.WithTimeout(ctx, options.DefaultHandlerTimeout)
 }
 
 func (node *Node) ping(from *rpc.MultiAddress) (*rpc.Nothing, error) {
 	// Update the DHT.
-	fromMultiAddress, err := rpc.DeserializeMultiAddress(from)
+	fromMultiAddress, err := deserializeMultiAddress(from)
 	if err != nil {
 		return &rpc.Nothing{}, err
 	}
 
 	// Notify the delegate of the ping.
-	node.Delegate.OnPingReceived(fromMultiAddress)
+	if node.Delegate != nil {
+		node.Delegate.OnPingReceived(fromMultiAddress)
+	}
 	return &rpc.Nothing{}, node.updatePeer(from)
 }
 
-func (node *Node) queryCloserPeers(query *rpc.Query) (*rpc.MultiAddresses, error) {
+func (node *Node) queryCloserPeers(query *rpc.Query, origin identity.Address) (*rpc.MultiAddresses, error) {
 	// Get the target identity.Address for which this Node is searching for
 	// peers.
 	target := identity.Address(query.Query.Address)
-	peers, err := node.DHT.FindMultiAddressNeighbors(target, node.Options.Alpha)
+	peersCloserToTarget, err := node.closerPeers(target, origin)
+	if err != nil {
+		return peersCloserToTarget, err
+	}
+
+	// Notify the delegate of the query.
+	fromMultiAddress, err := deserializeMultiAddress(query.From)
+	if err != nil {
+		return peersCloserToTarget, err
+	}
+	if node.Delegate != nil {
+		node.Delegate.OnQueryCloserPeersReceived(fromMultiAddress)
+	}
+	return peersCloserToTarget, node.updatePeer(query.From)
+}
+
+// closerPeers returns the peers in the dht.DHT that are closer to the target
+// identity.Address than the origin identity.Address, which is usually the
+// Node itself. Responses relative to the Node are cached for the QueryCacheTTL
+// option.
+func (node *Node) closerPeers(target, origin identity.Address) (*rpc.MultiAddresses, error) {
+	cacheable := origin == node.Address()
+	if cacheable {
+		if multiAddresses, ok := node.queryCache.get(target); ok {
+			return multiAddresses, nil
+		}
+	}
+	options := node.options()
+	peers, err := node.findMultiAddressNeighbors(target, node.Alpha())
 	if err != nil {
 		return &rpc.MultiAddresses{Multis: []*rpc.MultiAddress{}}, err
 	}
 
-	// Filter away peers that are further from the target than this Node.
+	// Filter away peers that are further from the target than the origin.
 	peersCloserToTarget := make(identity.MultiAddresses, 0, len(peers))
 	for _, peer := range peers {
-		closer, err := identity.Closer(peer.Address(), node.Address(), target)
+		closer, err := identity.Closer(peer.Address(), origin, target)
 		if err != nil {
-			return rpc.SerializeMultiAddresses(peersCloserToTarget), err
+			// A malformed peer is skipped, instead of dropping the rest of
+			// the response.
+			if options.Debug >= DebugLow {
+				log.Printf("%v cannot compare %v to %v: %v\n", node.Address(), peer.Address(), target, err)
+			}
+			continue
 		}
 		if closer {
 			peersCloserToTarget = append(peersCloserToTarget, peer)
 		}
 	}
 
-	// Notify the delegate of the query.
-	fromMultiAddress, err := rpc.DeserializeMultiAddress(query.From)
-	if err != nil {
-		return rpc.SerializeMultiAddresses(peersCloserToTarget), err
+	// When no peers are closer than this Node, fallback to the closest peers
+	// so that the querying Node can continue its lookup.
+	if len(peersCloserToTarget) == 0 && options.QueryFallbackPeers > 0 {
+		peersCloserToTarget = append(peersCloserToTarget, peers...)
+		sortByDistance(peersCloserToTarget, target)
+		peersCloserToTarget = truncate(peersCloserToTarget, options.QueryFallbackPeers)
+	}
+	if options.MaxQueryResults > 0 {
+		sortByDistance(peersCloserToTarget, target)
+		peersCloserToTarget = truncate(peersCloserToTarget, options.MaxQueryResults)
+	}
+	multiAddresses := rpc.SerializeMultiAddresses(peersCloserToTarget)
+	if cacheable {
+		node.queryCache.put(target, multiAddresses, options.QueryCacheTTL)
 	}
-	node.Delegate.OnQueryCloserPeersReceived(fromMultiAddress)
-	return rpc.SerializeMultiAddresses(peersCloserToTarget), node.updatePeer(query.From)
+	return multiAddresses, nil
 }
 
-func (node *Node) queryCloserPeersOnFrontier(query *rpc.Query, stream rpc.SwarmNode_QueryCloserPeersOnFrontierServer) error {
+func (node *Node) queryCloserPeersOnFrontier(ctx context.Context, query *rpc.Query, stream rpc.SwarmNode_QueryCloserPeersOnFrontierServer, stats *FrontierStats) error {
+	options := node.options()
 
 	// Get the target identity.Address for which this Node is searching for
 	// peers.
 	target := identity.Address(query.Query.Address)
 	peers := node.DHT.MultiAddresses()
+	if options.DeterministicFrontier {
+		sortByDistance(peers, target)
+	}
 
-	// Create the frontier and a closure map.
+	// Create the frontier and the set of peers that have been seen. A peer
+	// is only ever added to the frontier once, so a single set is enough to
+	// filter peers that are in the frontier, or have already been explored.
+	// The memory used by a traversal is bounded by the number of distinct
+	// peers that it finds.
 	frontier := make(identity.MultiAddresses, 0, len(peers))
-	black := make(map[identity.Address]struct{})
-	white := make(map[identity.Address]struct{})
+	seen := make(map[identity.Address]struct{}, len(peers)+1)
+	depths := make(map[identity.Address]int, len(peers))
+	failures := 0
+	queries := 0
 
 	// Filter away peers that are further from the target than this Node.
 	for _, peer := range peers {
 		closer, err := identity.Closer(peer.Address(), node.Address(), target)
 		if err != nil {
-			return err
+			if options.Debug >= DebugLow {
+				log.Printf("%v cannot compare %v to %v: %v\n", node.Address(), peer.Address(), target, err)
+			}
+			continue
 		}
 		if closer {
-			if err := stream.Send(rpc.SerializeMultiAddress(peer)); err != nil {
+			if err := node.sendMultiAddress(stream, peer); err != nil {
 				return err
 			}
 			frontier = append(frontier, peer)
+			depths[peer.Address()] = 1
+			stats.MaxDepth = 1
 		}
 	}
 
-	// Immediately close the Node that is running this query and mark all peers
-	// in the frontier as seen.
-	black[node.Address()] = struct{}{}
+	// Immediately mark the Node that is running this query, and all peers in
+	// the frontier, as seen.
+	seen[node.Address()] = struct{}{}
+	for _, peer := range frontier {
+		seen[peer.Address()] = struct{}{}
+	}
+
+	// The frontier is explored in rounds. A round explores the peers that
+	// were in the frontier when the round started, and the closest peer found
+	// is tracked so that convergence can be detected.
+	round, improved := len(frontier), true
+	var closest *identity.MultiAddress
 	for _, peer := range frontier {
-		white[peer.Address()] = struct{}{}
+		if node.closerThan(peer, closest, target) {
+			peer := peer
+			closest = &peer
+		}
 	}
 
 	// While there are still Nodes to be explored in the frontier.
 	for len(frontier) > 0 {
+		// Stop exploring as soon as the client has gone away, instead of
+		// querying peers for results that cannot be sent.
+		if stream.Context().Err() != nil {
+			return ErrClientDisconnected
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Start a new round. When the ConvergentFrontier option is set, stop
+		// exploring if the last round did not find a closer peer.
+		if round == 0 {
+			if options.ConvergentFrontier && !improved {
+				if options.Debug >= DebugMedium {
+					log.Printf("%v converged on %v\n", node.Address(), target)
+				}
+				break
+			}
+			round, improved = len(frontier), false
+		}
+
 		// Pop the first peer off the frontier.
 		peer := frontier[0]
 		frontier = frontier[1:]
+		round--
 
-		// Close the peer and use it to find peers that are even closer to the
-		// target.
-		black[peer.Address()] = struct{}{}
+		// Use the peer to find peers that are even closer to the target.
 		if peer.Address() == target {
 			continue
 		}
-		candidates, err := rpc.QueryCloserPeersFromTarget(peer, node.MultiAddress(), target, time.Second)
+		// Stop exploring when the budget of queries has been spent. The peers
+		// found so far have already been streamed.
+		if options.MaxFrontierQueries > 0 && queries >= options.MaxFrontierQueries {
+			if options.Debug >= DebugMedium {
+				log.Printf("%v spent its budget of %v frontier queries\n", node.Address(), queries)
+			}
+			break
+		}
+		queries++
+		stats.Visited = queries
+		queryCtx, cancel := context.WithTimeout(ctx, frontierHopTimeout(ctx))
+		candidates, err := node.queryCloserPeersFromTarget(queryCtx, peer, target)
+		cancel()
 		if err != nil {
-			if node.Options.Debug >= DebugLow {
+			if options.Debug >= DebugLow {
 				log.Println(err)
 			}
+			failures++
+			stats.Failures = failures
+			if options.StrictFrontier && failures > options.MaxFrontierFailures {
+				return ErrFrontierIncomplete
+			}
 			continue
 		}
 
-		// Filter any candidate that is already in the closure.
+		// Filter any candidate that has already been seen.
+		if options.DeterministicFrontier {
+			sortByDistance(candidates, target)
+		}
 		for _, candidate := range candidates {
-			if _, ok := black[candidate.Address()]; ok {
-				continue
-			}
-			if _, ok := white[candidate.Address()]; ok {
+			if _, ok := seen[candidate.Address()]; ok {
 				continue
 			}
 			// Expand the frontier by candidates that have not already been
 			// explored, and store them in a persistent list of close peers.
-			if err := stream.Send(rpc.SerializeMultiAddress(candidate)); err != nil {
+			if err := node.sendMultiAddress(stream, candidate); err != nil {
 				return err
 			}
 			frontier = append(frontier, candidate)
-			white[candidate.Address()] = struct{}{}
+			seen[candidate.Address()] = struct{}{}
+			depths[candidate.Address()] = depths[peer.Address()] + 1
+			if depths[candidate.Address()] > stats.MaxDepth {
+				stats.MaxDepth = depths[candidate.Address()]
+			}
+			if node.closerThan(candidate, closest, target) {
+				candidate := candidate
+				closest, improved = &candidate, true
+			}
+		}
+		if options.DeterministicFrontier {
+			sortByDistance(frontier, target)
 		}
 	}
 
-	fromMultiAddress, err := rpc.DeserializeMultiAddress(query.From)
+	fromMultiAddress, err := deserializeMultiAddress(query.From)
 	if err != nil {
 		return err
 	}
-	node.Delegate.OnQueryCloserPeersOnFrontierReceived(fromMultiAddress)
+	if node.Delegate != nil {
+		node.Delegate.OnQueryCloserPeersOnFrontierReceived(fromMultiAddress)
+	}
 	return node.updatePeer(query.From)
 }
 
-func (node *Node) bootstrapUsingMultiAddress(bootstrapMultiAddress identity.MultiAddress) error {
+// frontierHopTimeout returns the timeout for querying the next peer in a
+// frontier query. It is the DefaultFrontierHopTimeout, or the time remaining
+// before the deadline of the context if that is shorter, so that the
+// traversal finishes within the deadline of the client instead of overrunning
+// it.
+func frontierHopTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return DefaultFrontierHopTimeout
+	}
+	timeout := deadline.Sub(time.Now())
+	if timeout > DefaultFrontierHopTimeout {
+		return DefaultFrontierHopTimeout
+	}
+	return timeout
+}
+
+// closerThan returns true if the identity.MultiAddress is closer to the target
+// than the closest identity.MultiAddress, or if there is no closest
+// identity.MultiAddress.
+func (node *Node) closerThan(multiAddress identity.MultiAddress, closest *identity.MultiAddress, target identity.Address) bool {
+	if closest == nil {
+		return true
+	}
+	closer, err := identity.Closer(multiAddress.Address(), closest.Address(), target)
+	return err == nil && closer
+}
+
+// sendMultiAddress sends an identity.MultiAddress to the client of a frontier
+// query. Returns ErrClientDisconnected if it cannot be sent, so that a client
+// that has gone away can be distinguished from a failure in the network.
+func (node *Node) sendMultiAddress(stream rpc.SwarmNode_QueryCloserPeersOnFrontierServer, multiAddress identity.MultiAddress) error {
+	if err := stream.Send(rpc.SerializeMultiAddress(multiAddress)); err != nil {
+		if node.options().Debug >= DebugMedium {
+			log.Printf("%v cannot send to the frontier client: %v\n", node.Address(), err)
+		}
+		return ErrClientDisconnected
+	}
+	return nil
+}
+
+func (node *Node) bootstrapUsingMultiAddress(bootstrapMultiAddress identity.MultiAddress, target identity.Address) error {
+	peers, err := node.queryBootstrapMultiAddress(bootstrapMultiAddress, target)
+	if err != nil {
+		return err
+	}
+	node.insertBootstrapPeers(peers)
+	return nil
+}
+
+// queryBootstrapMultiAddress queries a bootstrap Node for the peers that are
+// close to the target identity.Address, without adding them to the dht.DHT.
+func (node *Node) queryBootstrapMultiAddress(bootstrapMultiAddress identity.MultiAddress, target identity.Address) (identity.MultiAddresses, error) {
+	options := node.options()
 	var err error
 	var peers identity.MultiAddresses
 
-	// The Node attempts to find itself in the network with three attempts
+	// The Node attempts to find the target in the network with three attempts
 	// backing off by 10 seconds per attempt.
-	for attempt := 0; attempt < node.Options.TimeoutRetries; attempt++ {
+	for attempt := 0; attempt < options.TimeoutRetries; attempt++ {
 		// Query the bootstrap node.
-		peers, err = rpc.QueryCloserPeersOnFrontierFromTarget(
-			bootstrapMultiAddress,
-			node.MultiAddress(),
-			node.Address(),
-			node.Options.Timeout+time.Duration(attempt)*node.Options.TimeoutStep,
-		)
+		ctx, cancel := context.WithTimeout(context.Background(), options.Timeout+time.Duration(attempt)*options.TimeoutStep)
+		peers, err = node.queryCloserPeersOnFrontierFromTarget(ctx, bootstrapMultiAddress, target)
+		cancel()
 		// Errors are not returned because it is reasonable that a bootstrap
 		// Node might be unavailable at this time.
 		if err == nil {
 			break
 		}
-		if node.Options.Debug >= DebugLow {
+		if options.Debug >= DebugLow {
 			log.Println(err)
 		}
-		if attempt == node.Options.TimeoutRetries-1 {
-			return err
+		if attempt == options.TimeoutRetries-1 {
+			return nil, err
 		}
 	}
 
-	// Peers returned by the query will be added to the DHT.
-	if node.Options.Debug >= DebugMedium {
+	if options.Debug >= DebugMedium {
 		log.Printf("%v received %v peers from %v.\n", node.Address(), len(peers), bootstrapMultiAddress.Address())
 	}
-	for _, peer := range peers {
-		if peer.Address() == node.Address() {
-			continue
-		}
-		if err := node.DHT.UpdateMultiAddress(peer); err != nil {
-			if node.Options.Debug >= DebugLow {
-				log.Println(err)
-			}
+	return peers, nil
+}
+
+// insertBootstrapPeers adds the peers returned by bootstrap Nodes to the
+// dht.DHT in a single batch. The BootstrapResultFilter option chooses which
+// of the peers are added.
+func (node *Node) insertBootstrapPeers(peers identity.MultiAddresses) {
+	options := node.options()
+	if filter := options.BootstrapResultFilter; filter != nil {
+		peers = filter(peers)
+	}
+	for _, err := range node.UpdateMultiAddresses(peers) {
+		if err != nil && err != ErrSelfAddress && options.Debug >= DebugLow {
+			log.Println(err)
 		}
 	}
-	return nil
 }
 
 func (node *Node) updatePeer(peer *rpc.MultiAddress) error {
-	multiAddress, err := rpc.DeserializeMultiAddress(peer)
+	options := node.options()
+	if node.IsDraining() {
+		return nil
+	}
+	multiAddress, err := deserializeMultiAddress(peer)
 	if err != nil {
 		return err
 	}
 	if multiAddress.Address() == node.Address() {
 		return nil
 	}
-	if err := node.DHT.UpdateMultiAddress(multiAddress); err != nil {
+	if filter := options.PeerFilter; filter != nil && !filter(multiAddress) {
+		if options.Debug >= DebugMedium {
+			log.Printf("%v is ignoring %v: %v\n", node.Address(), multiAddress, ErrPeerRejected)
+		}
+		return nil
+	}
+	err = node.addReachablePeer(multiAddress)
+	if err == nil {
+		node.touchPeer(multiAddress.Address())
+	}
+	if err != ErrSubnetLimit && err != ErrTableFull {
+		return err
+	}
+	if options.Debug >= DebugMedium {
+		log.Printf("%v is ignoring %v: %v\n", node.Address(), multiAddress, err)
+	}
+	return nil
+}
+
+// AddPeer adds an identity.MultiAddress to the dht.DHT, or updates it if it is
+// already in the dht.DHT. The identity.MultiAddress must be dialable, and must
+// not be the identity.MultiAddress of the Node. Returns ErrPeerRejected if the
+// PeerFilter option rejects it. When the bucket is full, the oldest peer in
+// the bucket is pruned to make space.
+func (node *Node) AddPeer(multiAddress identity.MultiAddress) error {
+	if err := node.checkPeer(multiAddress); err != nil {
+		return err
+	}
+	return node.addPeer(multiAddress)
+}
+
+// checkPeer returns an error if an identity.MultiAddress must not be added to
+// the dht.DHT, because it is the identity.MultiAddress of the Node, it is not
+// dialable, or the PeerFilter option rejects it.
+func (node *Node) checkPeer(multiAddress identity.MultiAddress) error {
+	if multiAddress.Address() == node.Address() {
+		return ErrSelfAddress
+	}
+	if _, err := dialAddress(multiAddress); err != nil {
+		return err
+	}
+	if filter := node.options().PeerFilter; filter != nil && !filter(multiAddress) {
+		return ErrPeerRejected
+	}
+	return nil
+}
+
+func (node *Node) addPeer(multiAddress identity.MultiAddress) error {
+	if err := node.updateMultiAddress(multiAddress); err != nil {
 		if err == dht.ErrFullBucket {
-			pruned, err := node.Prune(multiAddress.Address())
-			if err != nil {
-				return err
-			}
-			if pruned {
-				return node.DHT.UpdateMultiAddress(multiAddress)
-			}
-			return nil
+			return node.addPeerToFullBucket(multiAddress)
 		}
 		return err
 	}
 	return nil
 }
+
+// addPeerToFullBucket handles an identity.MultiAddress that could not be added
+// to the dht.DHT because its bucket is full, using the FullBucketStrategy
+// option.
+func (node *Node) addPeerToFullBucket(multiAddress identity.MultiAddress) error {
+	switch node.options().FullBucketStrategy {
+	case FullBucketReject:
+		return nil
+
+	case FullBucketReplaceCache:
+		return node.storeReplacement(multiAddress)
+
+	case FullBucketForceNewest:
+		oldest, err := node.oldestMultiAddress(multiAddress.Address())
+		if err != nil || oldest == nil {
+			return err
+		}
+		removed, err := node.removeOldestMultiAddress(multiAddress.Address(), *oldest)
+		if err != nil || !removed {
+			return err
+		}
+		return node.updateMultiAddress(multiAddress)
+
+	default:
+		pruned, err := node.Prune(multiAddress.Address())
+		if err != nil {
+			return err
+		}
+		if pruned {
+			return node.updateMultiAddress(multiAddress)
+		}
+		node.retryPeer(multiAddress)
+		return nil
+	}
+}
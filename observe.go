@@ -0,0 +1,50 @@
+package swarm
+
+import (
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	grpcpeer "google.golang.org/grpc/peer"
+)
+
+// ObservedAddressMetadataKey is the gRPC header that is sent in response to a
+// ping. It contains the network address that the pinging peer was observed
+// connecting from.
+const ObservedAddressMetadataKey = "swarm-observed-address"
+
+// Observe pings a peer and returns the network address that the peer observed
+// the Node connecting from. Nodes behind a NAT can use this to discover the
+// network address that they should advertise. Returns
+// ErrObservedAddressUnavailable if the peer does not report an observed
+// network address.
+func (node *Node) Observe(ctx context.Context, target identity.MultiAddress) (string, error) {
+	ctx = node.outbound(ctx, MethodPing, target)
+	if err := node.schedule(ctx); err != nil {
+		return "", err
+	}
+	conn, err := node.dial(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	header := metadata.MD{}
+	if _, err := rpc.NewSwarmNodeClient(conn).Ping(ctx, rpc.SerializeMultiAddress(node.MultiAddress()), grpc.Header(&header)); err != nil {
+		return "", err
+	}
+	observed := header[ObservedAddressMetadataKey]
+	if len(observed) == 0 {
+		return "", ErrObservedAddressUnavailable
+	}
+	return observed[0], nil
+}
+
+// setObservedAddressHeader sets the ObservedAddressMetadataKey header for the
+// response to an RPC.
+func setObservedAddressHeader(ctx context.Context) {
+	if observed, ok := grpcpeer.FromContext(ctx); ok && observed.Addr != nil {
+		grpc.SetHeader(ctx, metadata.Pairs(ObservedAddressMetadataKey, observed.Addr.String()))
+	}
+}
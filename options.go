@@ -1,9 +1,12 @@
 package swarm
 
 import (
+	"fmt"
+	"math/rand"
 	"time"
 
 	"github.com/republicprotocol/go-identity"
+	"google.golang.org/grpc"
 )
 
 // Constants for different options.
@@ -14,11 +17,93 @@ const (
 	DebugHigh   = 3
 )
 
+// Constants for the different identity.Addresses that can be looked up when
+// bootstrapping. An empty BootstrapLookupTargets option is equivalent to
+// BootstrapLookupSelf.
+const (
+	BootstrapLookupSelf                   = "self"
+	BootstrapLookupSelfAndRandomPerBucket = "self+random-per-bucket"
+)
+
+// Constants for the different ways that a Node can handle a peer whose bucket
+// is full. An empty FullBucketStrategy option is equivalent to
+// FullBucketPrune.
+const (
+	FullBucketPrune        = "prune"
+	FullBucketReject       = "reject"
+	FullBucketReplaceCache = "replace-cache"
+	FullBucketForceNewest  = "force-newest"
+)
+
+// Constants for the different ways that a Node can handle a new peer when the
+// dht.DHT holds MaxPeers peers. An empty EvictionPolicy option is equivalent
+// to EvictionRejectNew.
+const (
+	EvictionRejectNew   = "reject-new"
+	EvictionMostCrowded = "most-crowded"
+)
+
+// DefaultRTTSmoothingFactor is used when the RTTSmoothingFactor option is not
+// in the range (0, 1].
+const DefaultRTTSmoothingFactor = 0.125
+
+// DefaultBootstrapWorkers is used when the BootstrapWorkers option is less
+// than one.
+const DefaultBootstrapWorkers = 16
+
+// DefaultMaxLookupRounds is used when the MaxLookupRounds option is less than
+// one.
+const DefaultMaxLookupRounds = 64
+
+// DefaultBucketCoverageTimeout is used when the BucketCoverageTimeout option
+// is not positive.
+const DefaultBucketCoverageTimeout = 30 * time.Second
+
+// DefaultPendingPeerBackoff is used when the PendingPeerBackoff option is not
+// positive.
+const DefaultPendingPeerBackoff = time.Second
+
+// DefaultFrontierHopTimeout is the longest that a frontier query waits for a
+// response from each peer that it queries.
+const DefaultFrontierHopTimeout = time.Second
+
 // Options that parameterize the behavior of Nodes.
 type Options struct {
 	MultiAddress            identity.MultiAddress
 	BootstrapMultiAddresses identity.MultiAddresses
 
+	// Version is reported to peers in response to a ping, so that the
+	// versions of the Nodes in a network can be inventoried.
+	Version string
+
+	// ExposeStats reports the occupancy of the dht.DHT to peers in response to
+	// a ping. It should not be set on public Nodes that do not want to reveal
+	// their routing table.
+	ExposeStats bool
+
+	// PeerSource supplies the identity.MultiAddresses that are used to
+	// bootstrap, each time that the Node bootstraps. If it is nil, the
+	// BootstrapMultiAddresses option is used.
+	PeerSource PeerSource
+
+	// BootstrapSeeds are bootstrap identity.MultiAddresses with priorities.
+	// They are used in addition to the BootstrapMultiAddresses, which have a
+	// priority of zero. In concurrent mode, each lower priority is started
+	// after the BootstrapStagger.
+	BootstrapSeeds   []BootstrapSeed
+	BootstrapStagger time.Duration
+
+	// BootstrapWorkers is the maximum number of goroutines that query
+	// bootstrap Nodes at the same time when the Concurrent option is set. This
+	// bounds the goroutines and connections that are opened when bootstrapping
+	// from a large list of bootstrap Nodes.
+	BootstrapWorkers int
+
+	// RequireNonSeedPeers makes Bootstrap return ErrOnlySeedPeers when every
+	// peer in the dht.DHT is a bootstrap Node, because the Node has connected
+	// to the bootstrap Nodes without joining the network.
+	RequireNonSeedPeers bool
+
 	Debug           int
 	Alpha           int
 	MaxBucketLength int
@@ -26,4 +111,265 @@ type Options struct {
 	TimeoutStep     time.Duration
 	TimeoutRetries  int
 	Concurrent      bool
+
+	// AdaptiveAlpha replaces the Alpha option with the base two logarithm of
+	// the number of peers in the dht.DHT, clamped between MinAlpha and
+	// MaxAlpha. A MaxAlpha of zero does not limit the Alpha. The Alpha is
+	// never less than one, so that a Node with few peers still queries them.
+	AdaptiveAlpha bool
+	MinAlpha      int
+	MaxAlpha      int
+
+	// BootstrapLookupTargets determines which identity.Addresses are looked
+	// up when bootstrapping. Looking up random identity.Addresses, in addition
+	// to the Node itself, populates buckets that are far away from the Node.
+	BootstrapLookupTargets string
+
+	// BootstrapResultFilter is given the peers returned by bootstrap Nodes,
+	// and returns the peers that are added to the dht.DHT. It can be used to
+	// prefer trusted peers, or to shape the buckets, while the dht.DHT is
+	// formed. It is called without holding any locks of the Node. If it is
+	// nil, all peers are added.
+	BootstrapResultFilter func(identity.MultiAddresses) identity.MultiAddresses
+
+	// AutoReBootstrap is how long the number of peers in the dht.DHT must be
+	// below MinPeers before the Node bootstraps again. A MinPeers of zero is
+	// treated as one, so that the Node bootstraps again after losing all of
+	// its peers. Zero disables bootstrapping again.
+	AutoReBootstrap time.Duration
+	MinPeers        int
+
+	// MinBucketsCovered is the number of buckets that must have at least one
+	// peer before Bootstrap succeeds. Bootstrap looks up random
+	// identity.Addresses in empty buckets until enough buckets are covered,
+	// or until the BucketCoverageTimeout has passed. Zero only requires that
+	// the bootstrap Nodes respond.
+	MinBucketsCovered     int
+	BucketCoverageTimeout time.Duration
+
+	// EnableCompression compresses RPCs using gzip. The gRPC server of the
+	// Node must be created using the ServerOptions, and all Nodes in the
+	// network must enable compression.
+	EnableCompression bool
+
+	// OutboundMiddleware is called before each outbound RPC made by the Node.
+	// It can be nil.
+	OutboundMiddleware OutboundMiddleware
+
+	// QueryFallbackPeers is the number of peers that are returned in response
+	// to a query when no peers are closer to the target than the Node. The
+	// closest peers are returned, even though they are further away than the
+	// Node. Zero disables the fallback.
+	QueryFallbackPeers int
+
+	// MaxQueryResults is the maximum number of identity.MultiAddresses in the
+	// response to a query. The closest peers to the target are kept. This
+	// bounds the size of responses independently of the MaxBucketLength.
+	// Zero does not limit responses.
+	MaxQueryResults int
+
+	// MaxBatchTargets is the maximum number of targets that can be queried
+	// by QueryCloserPeersForTargets. Zero allows an unlimited number of
+	// targets.
+	MaxBatchTargets int
+
+	// QueryCacheTTL is how long the response to a query for a target
+	// identity.Address is cached. Zero disables caching.
+	QueryCacheTTL time.Duration
+
+	// LookupCacheTTL is how long the result of a lookup for a target
+	// identity.Address is cached. Cached results that include a peer are
+	// invalidated when the peer is found to be dead. Zero disables caching.
+	LookupCacheTTL time.Duration
+
+	// MinPingInterval is the minimum time between pings to the same peer.
+	// When a peer is pinged again within the interval, such as by pruning and
+	// by verifying reachability, the result of the last ping is used instead.
+	// Zero disables the interval.
+	MinPingInterval time.Duration
+
+	// MaxOutboundRate is the maximum number of outbound RPCs per second, with
+	// bursts of up to one second of RPCs. Maintenance RPCs wait for RPCs made
+	// by lookups and other callers, unless their priority is set using
+	// WithPriority. Zero does not limit outbound RPCs.
+	MaxOutboundRate float64
+
+	// DefaultHandlerTimeout is the deadline applied to RPCs that are received
+	// without a deadline. Zero allows such RPCs to run without a deadline.
+	DefaultHandlerTimeout time.Duration
+
+	// FullBucketStrategy determines what happens to a new peer when its bucket
+	// is full. FullBucketPrune pings the oldest peer in the bucket and
+	// replaces it if it is dead. FullBucketReject drops the new peer.
+	// FullBucketReplaceCache stores the new peer in a replacement cache, and
+	// adds it when a peer is removed from the bucket. FullBucketForceNewest
+	// always replaces the oldest peer in the bucket.
+	FullBucketStrategy string
+
+	// MaxPeers is the maximum number of peers in the dht.DHT, across all
+	// buckets. Zero allows every bucket to fill up to the MaxBucketLength.
+	MaxPeers int
+
+	// EvictionPolicy determines what happens to a new peer when the dht.DHT
+	// holds MaxPeers peers. EvictionRejectNew drops the new peer.
+	// EvictionMostCrowded removes the oldest peer in the most crowded bucket,
+	// unless the bucket of the new peer would become at least as crowded.
+	// This keeps peers in sparse buckets, so that the dht.DHT covers the
+	// address space evenly when memory is limited.
+	EvictionPolicy string
+
+	// ProtectBucketFillers admits a new peer that would be the first in its
+	// bucket when the dht.DHT holds MaxPeers peers, by evicting the oldest
+	// peer in the most crowded bucket, even when the EvictionPolicy would
+	// reject it. Peers that are the only peer in their bucket are never
+	// evicted to make space, so the dht.DHT keeps its reach.
+	ProtectBucketFillers bool
+
+	// SelfAddressQuarantineThreshold is the number of times that a peer can
+	// return the identity.Address of the Node in response to a query, when
+	// the Node is not closer to the target than the peer, before it is
+	// quarantined. Quarantined peers are not queried by lookups. Zero
+	// disables the quarantine.
+	SelfAddressQuarantineThreshold int
+
+	// QuarantineDuration is how long a peer is quarantined for, and how long
+	// its offences are remembered.
+	QuarantineDuration time.Duration
+
+	// VerifyReachability requires a Node to ping a new peer that contacted
+	// it before adding the peer to the dht.DHT. Peers that cannot be reached,
+	// such as peers behind a NAT, are not added.
+	VerifyReachability bool
+
+	// ProbationPings is the number of times that a new peer that contacted
+	// the Node is pinged, spread over the ProbationDuration, before it is
+	// added to the dht.DHT. A peer that fails any ping is not added. The
+	// ProbationDuration should be longer than the MinPingInterval times the
+	// ProbationPings, otherwise pings are coalesced. Zero disables probation,
+	// unless VerifyReachability is set, in which case the peer is pinged once.
+	ProbationPings    int
+	ProbationDuration time.Duration
+
+	// DeterministicFrontier sorts the frontier of a frontier query by
+	// distance to the target after each expansion, instead of exploring it in
+	// the order that peers were found. Given a fixed topology, peers are then
+	// explored and streamed in the same order every time, which is useful for
+	// reproducible tests.
+	DeterministicFrontier bool
+
+	// StrictFrontier aborts a frontier query with ErrFrontierIncomplete when
+	// more than MaxFrontierFailures of its queries to peers fail. Otherwise,
+	// peers that fail are skipped and the traversal continues.
+	StrictFrontier      bool
+	MaxFrontierFailures int
+
+	// MaxFrontierQueries is the maximum number of peers that a frontier query
+	// can query. When it is reached, the traversal stops and the peers found
+	// so far are returned. Zero allows an unlimited number of queries.
+	MaxFrontierQueries int
+
+	// ConvergentFrontier stops a frontier query when a round of queries does
+	// not find a peer that is closer to the target than the closest peer
+	// already found. Otherwise, the frontier is explored until it is empty.
+	ConvergentFrontier bool
+
+	// MaxPeersPerSubnet is the maximum number of peers in the dht.DHT that
+	// can share a /24 IPv4 subnet, or a /48 IPv6 subnet. This stops a single
+	// host from filling the buckets with many identities. Zero allows an
+	// unlimited number of peers per subnet.
+	MaxPeersPerSubnet int
+
+	// StalenessTTL is how long a peer can go without being seen before it is
+	// considered stale by a StalenessReport. Zero considers no peers stale.
+	StalenessTTL time.Duration
+
+	// HedgeFactor is the number of peers that a lookup queries at the same
+	// time in each round, when it is greater than one. The round ends when
+	// the first peer responds, and the queries to the other peers are
+	// cancelled. This reduces the effect of slow peers on lookups, at the
+	// cost of bandwidth. Otherwise, Alpha peers are queried in each round and
+	// the round waits for all of them.
+	HedgeFactor int
+
+	// MaxLookupRounds is the maximum number of rounds that a lookup can run
+	// before it stops with ErrLookupLoop. This bounds lookups in networks
+	// where peers keep returning each other.
+	MaxLookupRounds int
+
+	// PeerFilter is called before a peer is added to the dht.DHT. The peer is
+	// rejected if it returns false. It is called without holding any locks of
+	// the Node. If it is nil, all peers are accepted.
+	PeerFilter func(identity.MultiAddress) bool
+
+	// PendingPeerRetries is the number of times that a Node retries adding a
+	// peer whose bucket was full, and could not be pruned. The first retry is
+	// after PendingPeerBackoff, and the backoff doubles after each retry.
+	// Zero disables retries. When the PendingPeerBackoff is not positive, the
+	// DefaultPendingPeerBackoff is used.
+	PendingPeerRetries int
+	PendingPeerBackoff time.Duration
+
+	// ValueStore stores the Values held by the Node. If it is nil, Values are
+	// stored in memory.
+	ValueStore ValueStore
+
+	// RandomSource is used to generate random identity.Addresses, such as the
+	// targets of lookups that populate buckets. If it is nil, a source seeded
+	// with the current time is used.
+	RandomSource rand.Source
+
+	// RTTSmoothingFactor is the weight given to a new round trip time sample
+	// when updating the moving average round trip time of a peer.
+	RTTSmoothingFactor float64
+}
+
+// Validate returns an error if the Options cannot be used to create a Node.
+// The identity.ID of the MultiAddress must have a length of identity.IDLength
+// bytes, otherwise every operation on the buckets of the dht.DHT would fail.
+func (options Options) Validate() error {
+	if length := len(options.MultiAddress.Address().ID()); length != identity.IDLength {
+		return fmt.Errorf("%v: %v has %v bytes, expected %v", ErrInvalidAddressLength, options.MultiAddress.Address(), length, identity.IDLength)
+	}
+	return nil
+}
+
+// SetOptions updates the Options of a running Node. The mutator is given a
+// copy of the current Options, and the copy replaces them once it returns.
+// Returns ErrImmutableOption, without changing the Options, if the mutator
+// changes the MultiAddress or the MaxBucketLength, because the dht.DHT has
+// already been created using them. Changing the AutoReBootstrap option starts
+// or stops bootstrapping again, as if the Node had been created with it. The
+// mutator must not call methods of the Node.
+func (node *Node) SetOptions(mutator func(*Options)) error {
+	node.optionsMu.Lock()
+	defer node.optionsMu.Unlock()
+
+	options := node.Options
+	mutator(&options)
+	if options.MultiAddress.String() != node.Options.MultiAddress.String() || options.MaxBucketLength != node.Options.MaxBucketLength {
+		return ErrImmutableOption
+	}
+	if options.AutoReBootstrap != node.Options.AutoReBootstrap {
+		node.restartAutoReBootstrapUnsafe(options.AutoReBootstrap)
+	}
+	node.Options = options
+	return nil
+}
+
+// options returns a copy of the current Options of the Node. It must be used
+// to read the Options, because they can be changed by SetOptions.
+func (node *Node) options() Options {
+	node.optionsMu.RLock()
+	defer node.optionsMu.RUnlock()
+	return node.Options
+}
+
+// ServerOptions returns the grpc.ServerOptions that are needed by the gRPC
+// server of a Node with these Options.
+func (options Options) ServerOptions() []grpc.ServerOption {
+	serverOptions := []grpc.ServerOption{}
+	if options.EnableCompression {
+		serverOptions = append(serverOptions, grpc.RPCCompressor(grpc.NewGZIPCompressor()), grpc.RPCDecompressor(grpc.NewGZIPDecompressor()))
+	}
+	return serverOptions
 }
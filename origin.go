@@ -0,0 +1,36 @@
+package swarm
+
+import (
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// OriginMetadataKey is the gRPC metadata that can be sent with a query to ask
+// for the peers that are closer to the target than the origin
+// identity.Address, instead of the queried Node. This lets a Node answer
+// lookups on behalf of another Node, such as when relaying between partitions
+// of the network.
+const OriginMetadataKey = "swarm-origin"
+
+// WithOrigin returns a context that sends the origin identity.Address with
+// the queries that use it.
+func WithOrigin(ctx context.Context, origin identity.Address) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, metadata.Join(md, metadata.Pairs(OriginMetadataKey, string(origin))))
+}
+
+// origin returns the origin identity.Address sent with a query, or the
+// identity.Address of the Node if no origin was sent. Returns
+// ErrInvalidAddress if the origin cannot be decoded.
+func (node *Node) origin(ctx context.Context) (identity.Address, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[OriginMetadataKey]) == 0 || md[OriginMetadataKey][0] == "" {
+		return node.Address(), nil
+	}
+	origin := identity.Address(md[OriginMetadataKey][0])
+	if len(origin.ID()) == 0 {
+		return "", ErrInvalidAddress
+	}
+	return origin, nil
+}
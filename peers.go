@@ -0,0 +1,185 @@
+package swarm
+
+import (
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+)
+
+// A peer stores the data that a Node associates with an identity.MultiAddress
+// in its dht.DHT. It is dropped when the identity.MultiAddress is removed from
+// the dht.DHT by the Node.
+type peer struct {
+	metadata map[string]string
+	rtt      time.Duration
+
+	// sequence orders peers by when they were last updated. Unlike a
+	// timestamp, it is not affected by adjustments to the clock.
+	sequence uint64
+
+	// added is the time at which the peer was added to the dht.DHT, and
+	// lastSeen is the time of the last successful contact with the peer. A
+	// peer that was added long ago, but contacted recently, is fresh. They are
+	// reported to clients, which cannot compare sequences.
+	added    time.Time
+	lastSeen time.Time
+}
+
+// A PeerEntry is a snapshot of a peer in the dht.DHT, and the data that the
+// Node associates with it.
+type PeerEntry struct {
+	MultiAddress identity.MultiAddress
+	Added        time.Time
+	LastSeen     time.Time
+	RTT          time.Duration
+	Metadata     map[string]string
+}
+
+// Filter returns the identity.MultiAddresses in the dht.DHT whose PeerEntry
+// satisfies the predicate, such as peers that were seen recently, or that
+// have a metadata flag. The predicate is called while holding the locks of the
+// Node, so it must not call methods of the Node.
+func (node *Node) Filter(predicate func(PeerEntry) bool) identity.MultiAddresses {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	return node.filterUnsafe(node.DHT.MultiAddresses(), predicate)
+}
+
+// FilterBucket is the same as Filter, but only considers the bucket that the
+// target identity.Address belongs in.
+func (node *Node) FilterBucket(target identity.Address, predicate func(PeerEntry) bool) (identity.MultiAddresses, error) {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	bucket, err := node.findBucket(target)
+	if err != nil {
+		return nil, err
+	}
+	if bucket == nil {
+		return identity.MultiAddresses{}, nil
+	}
+	return node.filterUnsafe(bucket.MultiAddresses, predicate), nil
+}
+
+// filterUnsafe returns the identity.MultiAddresses whose PeerEntry satisfies
+// the predicate. The dhtMu must be locked for reading.
+func (node *Node) filterUnsafe(multiAddresses identity.MultiAddresses, predicate func(PeerEntry) bool) identity.MultiAddresses {
+	node.peersMu.RLock()
+	defer node.peersMu.RUnlock()
+	filtered := identity.MultiAddresses{}
+	for _, multiAddress := range multiAddresses {
+		entry := PeerEntry{MultiAddress: multiAddress}
+		if p, ok := node.peers[multiAddress.Address()]; ok {
+			entry.Added, entry.LastSeen, entry.RTT = p.added, p.lastSeen, p.rtt
+			entry.Metadata = copyMetadata(p.metadata)
+		}
+		if predicate(entry) {
+			filtered = append(filtered, multiAddress)
+		}
+	}
+	return filtered
+}
+
+// SetMetadata associates metadata with a peer in the dht.DHT, replacing any
+// metadata that was previously associated with it. Returns ErrPeerNotFound if
+// the identity.Address is not in the dht.DHT.
+func (node *Node) SetMetadata(address identity.Address, metadata map[string]string) error {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	multiAddress, err := node.findMultiAddress(address)
+	if err != nil {
+		return err
+	}
+	if multiAddress == nil {
+		return ErrPeerNotFound
+	}
+
+	node.peersMu.Lock()
+	defer node.peersMu.Unlock()
+	node.peer(address).metadata = copyMetadata(metadata)
+	return nil
+}
+
+// Metadata returns a copy of the metadata associated with a peer in the
+// dht.DHT. Returns nil if no metadata is associated with the identity.Address.
+func (node *Node) Metadata(address identity.Address) map[string]string {
+	node.peersMu.RLock()
+	defer node.peersMu.RUnlock()
+	if p, ok := node.peers[address]; ok {
+		return copyMetadata(p.metadata)
+	}
+	return nil
+}
+
+// RTT returns the exponential moving average of the round trip time to a peer
+// in the dht.DHT. Returns zero if no round trip time has been measured.
+func (node *Node) RTT(address identity.Address) time.Duration {
+	node.peersMu.RLock()
+	defer node.peersMu.RUnlock()
+	if p, ok := node.peers[address]; ok {
+		return p.rtt
+	}
+	return 0
+}
+
+// updateRTT adds a round trip time sample to the moving average of a peer, and
+// records that the peer was seen. Samples for identity.Addresses that are not
+// in the dht.DHT are ignored.
+func (node *Node) updateRTT(address identity.Address, rtt time.Duration) {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	multiAddress, err := node.findMultiAddress(address)
+	if err != nil || multiAddress == nil {
+		return
+	}
+	factor := node.options().RTTSmoothingFactor
+	if factor <= 0 || factor > 1 {
+		factor = DefaultRTTSmoothingFactor
+	}
+
+	node.peersMu.Lock()
+	defer node.peersMu.Unlock()
+	p := node.peer(address)
+	p.lastSeen = time.Now()
+	if p.rtt == 0 {
+		p.rtt = rtt
+		return
+	}
+	p.rtt = time.Duration(factor*float64(rtt) + (1-factor)*float64(p.rtt))
+}
+
+// touchPeer records that a peer was seen, because it contacted the Node.
+// identity.Addresses that are not in the dht.DHT are ignored.
+func (node *Node) touchPeer(address identity.Address) {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	multiAddress, err := node.findMultiAddress(address)
+	if err != nil || multiAddress == nil {
+		return
+	}
+
+	node.peersMu.Lock()
+	defer node.peersMu.Unlock()
+	node.peer(address).lastSeen = time.Now()
+}
+
+// peer returns the data associated with an identity.Address, creating it if
+// it does not exist. The peersMu must be locked for writing.
+func (node *Node) peer(address identity.Address) *peer {
+	p, ok := node.peers[address]
+	if !ok {
+		p = &peer{}
+		node.peers[address] = p
+	}
+	return p
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,205 @@
+package swarm_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-swarm-network"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Peers", func() {
+
+	var node, peer *swarm.Node
+
+	BeforeEach(func() {
+		nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+		Ω(err).ShouldNot(HaveOccurred())
+		node, peer = nodes[0], nodes[1]
+	})
+
+	Context("when setting metadata", func() {
+
+		It("should return an error for unknown peers", func() {
+			err := node.SetMetadata(peer.Address(), map[string]string{"role": "relay"})
+			Ω(err).Should(Equal(swarm.ErrPeerNotFound))
+			Ω(node.Metadata(peer.Address())).Should(BeNil())
+		})
+
+		It("should return an error for its own address", func() {
+			err := node.SetMetadata(node.Address(), map[string]string{"role": "relay"})
+			Ω(err).Should(Equal(swarm.ErrSelfAddress))
+		})
+
+		It("should store a copy of the metadata for known peers", func() {
+			Ω(node.DHT.UpdateMultiAddress(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			metadata := map[string]string{"role": "relay"}
+			Ω(node.SetMetadata(peer.Address(), metadata)).ShouldNot(HaveOccurred())
+			metadata["role"] = "storage"
+			Ω(node.Metadata(peer.Address())).Should(Equal(map[string]string{"role": "relay"}))
+		})
+	})
+
+	Context("when adding peers", func() {
+
+		It("should record when the peer was added, but not seen", func() {
+			Ω(node.Added(peer.Address()).IsZero()).Should(BeTrue())
+			Ω(node.AddPeer(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.Added(peer.Address()).IsZero()).Should(BeFalse())
+			Ω(node.LastSeen(peer.Address()).IsZero()).Should(BeTrue())
+		})
+
+		It("should reject multi-addresses that cannot be dialed", func() {
+			for _, format := range []string{"/ip4/127.0.0.1/republic/%s", "/tcp/4000/republic/%s"} {
+				multiAddress, err := identity.NewMultiAddressFromString(fmt.Sprintf(format, peer.Address()))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(node.AddPeer(multiAddress)).Should(Equal(swarm.ErrInvalidMultiAddress))
+			}
+			Ω(node.Contains(peer.Address())).Should(BeFalse())
+		})
+
+		It("should accept IPv6 multi-addresses", func() {
+			multiAddress, err := identity.NewMultiAddressFromString(fmt.Sprintf("/ip6/::1/tcp/4000/republic/%s", peer.Address()))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(node.AddPeer(multiAddress)).ShouldNot(HaveOccurred())
+			Ω(node.Contains(peer.Address())).Should(BeTrue())
+		})
+	})
+
+	Context("when reporting staleness", func() {
+
+		It("should recommend no action for fresh peers", func() {
+			node.Options.StalenessTTL = time.Hour
+			Ω(node.AddPeer(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			report := node.StalenessReport()
+			Ω(report.NumberOfPeers).Should(Equal(1))
+			Ω(report.NumberOfStalePeers).Should(Equal(0))
+			Ω(report.Action).Should(Equal(swarm.StalenessActionNone))
+		})
+	})
+
+	Context("when filtering peers", func() {
+
+		It("should reject peers that do not pass the filter", func() {
+			node.Options.PeerFilter = func(multiAddress identity.MultiAddress) bool {
+				return false
+			}
+			Ω(node.AddPeer(peer.MultiAddress())).Should(Equal(swarm.ErrPeerRejected))
+			Ω(node.Contains(peer.Address())).Should(BeFalse())
+		})
+	})
+
+	Context("when pinging targets", func() {
+
+		It("should stream a result for each target", func() {
+			results := node.PingTargets(context.Background(), identity.MultiAddresses{peer.MultiAddress()})
+			result, ok := <-results
+			Ω(ok).Should(BeTrue())
+			Ω(result.MultiAddress.String()).Should(Equal(peer.MultiAddress().String()))
+			Ω(result.Err).Should(HaveOccurred())
+			Eventually(results).Should(BeClosed())
+		})
+
+		// ping pings the peer, and returns the error of the ping if there is
+		// a result.
+		ping := func(ctx context.Context) error {
+			var err error
+			for result := range node.PingTargets(ctx, identity.MultiAddresses{peer.MultiAddress()}) {
+				err = result.Err
+			}
+			return err
+		}
+
+		It("should reuse the result of a recent ping", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			node.Options.MinPingInterval = time.Second
+			stop, err := ServeNodes([]*swarm.Node{peer})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ping(context.Background())).ShouldNot(HaveOccurred())
+			stop()
+
+			Ω(ping(context.Background())).ShouldNot(HaveOccurred())
+		})
+
+		It("should not reuse the result of a cancelled ping", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			node.Options.MinPingInterval = time.Second
+			silent, err := ListenSilently(peer)
+			Ω(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			ping(ctx)
+			silent.Close()
+
+			stop, err := ServeNodes([]*swarm.Node{peer})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+			Ω(ping(context.Background())).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("when sampling peers", func() {
+
+		It("should sample each peer at most once", func() {
+			Ω(node.AddPeer(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			for _, strategy := range []string{swarm.SampleUniform, swarm.SampleOnePerBucket, swarm.SampleDistanceWeighted} {
+				sample := node.SamplePeers(2, strategy)
+				Ω(sample).Should(HaveLen(1))
+				Ω(sample[0].String()).Should(Equal(peer.MultiAddress().String()))
+			}
+		})
+	})
+
+	Context("when filtering the DHT", func() {
+
+		It("should return the peers that satisfy the predicate", func() {
+			Ω(node.AddPeer(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(node.SetMetadata(peer.Address(), map[string]string{"role": "relay"})).ShouldNot(HaveOccurred())
+			relays := node.Filter(func(entry swarm.PeerEntry) bool {
+				return entry.Metadata["role"] == "relay"
+			})
+			Ω(relays).Should(HaveLen(1))
+			Ω(node.Filter(func(entry swarm.PeerEntry) bool {
+				return !entry.LastSeen.IsZero()
+			})).Should(BeEmpty())
+		})
+	})
+
+	Context("when querying the stats of a peer", func() {
+
+		It("should return the occupancy of its dht when it exposes its stats", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			peer.Options.ExposeStats = true
+			Ω(peer.AddPeer(node.MultiAddress())).ShouldNot(HaveOccurred())
+			stop, err := ServeNodes([]*swarm.Node{peer})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			stats, err := node.QueryStats(context.Background(), peer.MultiAddress())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stats.NumberOfPeers).Should(Equal(1))
+			Ω(stats.BucketLengths).Should(Equal(peer.Info().BucketLengths))
+		})
+
+		It("should return an error when it does not expose its stats", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			stop, err := ServeNodes([]*swarm.Node{peer})
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			_, err = node.QueryStats(context.Background(), peer.MultiAddress())
+			Ω(err).Should(Equal(swarm.ErrStatsUnavailable))
+		})
+	})
+})
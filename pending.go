@@ -0,0 +1,101 @@
+package swarm
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-dht"
+	"github.com/republicprotocol/go-identity"
+)
+
+// MaxPendingPeers is the maximum number of peers that can be waiting to be
+// retried at the same time. Peers are dropped when there are too many pending
+// peers.
+const MaxPendingPeers = 256
+
+// pendingPeers are the peers that could not be added to the dht.DHT because
+// their bucket was full, and that are waiting to be retried.
+type pendingPeers struct {
+	mu      *sync.Mutex
+	entries map[identity.Address]struct{}
+}
+
+func newPendingPeers() *pendingPeers {
+	return &pendingPeers{
+		mu:      new(sync.Mutex),
+		entries: map[identity.Address]struct{}{},
+	}
+}
+
+// retryPeer retries adding an identity.MultiAddress to the dht.DHT in the
+// background, backing off after each attempt. Buckets that are full can have
+// space a short time later, so retrying stops valuable peers from being lost
+// when buckets oscillate between being full and not full.
+func (node *Node) retryPeer(multiAddress identity.MultiAddress) {
+	options := node.options()
+	if options.PendingPeerRetries <= 0 {
+		return
+	}
+	node.pending.mu.Lock()
+	if _, ok := node.pending.entries[multiAddress.Address()]; ok || len(node.pending.entries) >= MaxPendingPeers {
+		node.pending.mu.Unlock()
+		return
+	}
+	node.pending.entries[multiAddress.Address()] = struct{}{}
+	node.pending.mu.Unlock()
+
+	go func() {
+		defer func() {
+			node.pending.mu.Lock()
+			delete(node.pending.entries, multiAddress.Address())
+			node.pending.mu.Unlock()
+		}()
+
+		backoff := options.PendingPeerBackoff
+		if backoff <= 0 {
+			backoff = DefaultPendingPeerBackoff
+		}
+		for attempt := 0; attempt < options.PendingPeerRetries; attempt++ {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-node.done:
+				timer.Stop()
+				return
+			}
+			backoff *= 2
+			if node.MaintenancePaused() {
+				continue
+			}
+
+			added, err := node.retryPeerOnce(multiAddress)
+			if err != nil {
+				if options.Debug >= DebugLow {
+					log.Println(err)
+				}
+				return
+			}
+			if added {
+				return
+			}
+		}
+		if options.Debug >= DebugMedium {
+			log.Printf("%v gave up adding %v\n", node.Address(), multiAddress.Address())
+		}
+	}()
+}
+
+// retryPeerOnce attempts to add an identity.MultiAddress to the dht.DHT,
+// pruning its bucket if it is full. Returns true if it was added.
+func (node *Node) retryPeerOnce(multiAddress identity.MultiAddress) (bool, error) {
+	err := node.updateMultiAddress(multiAddress)
+	if err != dht.ErrFullBucket {
+		return err == nil, err
+	}
+	pruned, err := node.Prune(multiAddress.Address())
+	if err != nil || !pruned {
+		return false, err
+	}
+	return true, node.updateMultiAddress(multiAddress)
+}
@@ -0,0 +1,83 @@
+package swarm
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+)
+
+// A quarantine tracks peers that have returned the identity.Address of the
+// Node as a peer that is closer to the target than themselves, when the Node
+// is not. A correct peer only returns peers that are closer than itself, so
+// peers that do so repeatedly are quarantined and are not queried by lookups
+// until the quarantine expires.
+type quarantine struct {
+	mu      *sync.Mutex
+	entries map[identity.Address]quarantineEntry
+}
+
+type quarantineEntry struct {
+	offences int
+	last     time.Time
+	until    time.Time
+}
+
+func newQuarantine() *quarantine {
+	return &quarantine{
+		mu:      new(sync.Mutex),
+		entries: map[identity.Address]quarantineEntry{},
+	}
+}
+
+// IsQuarantined returns true if the peer is quarantined for returning the
+// identity.Address of the Node, otherwise false.
+func (node *Node) IsQuarantined(address identity.Address) bool {
+	node.quarantine.mu.Lock()
+	defer node.quarantine.mu.Unlock()
+	entry, ok := node.quarantine.entries[address]
+	return ok && time.Now().Before(entry.until)
+}
+
+// reportSelfAddress records that a peer returned the identity.Address of the
+// Node in response to a query. The peer is quarantined for the
+// QuarantineDuration option once it has done so SelfAddressQuarantineThreshold
+// times. A threshold of zero disables the quarantine. Offences are forgotten
+// after the QuarantineDuration, so that the quarantine does not grow with
+// every peer that has ever misbehaved.
+func (node *Node) reportSelfAddress(address identity.Address) {
+	options := node.options()
+	if options.Debug >= DebugLow {
+		log.Printf("%v received its own address from %v\n", node.Address(), address)
+	}
+	if options.SelfAddressQuarantineThreshold <= 0 {
+		return
+	}
+
+	now := time.Now()
+	node.quarantine.mu.Lock()
+	defer node.quarantine.mu.Unlock()
+	node.quarantine.expireUnsafe(now, options.QuarantineDuration)
+	entry := node.quarantine.entries[address]
+	entry.offences++
+	entry.last = now
+	if entry.offences >= options.SelfAddressQuarantineThreshold {
+		entry.offences = 0
+		entry.until = now.Add(options.QuarantineDuration)
+		if options.Debug >= DebugLow {
+			log.Printf("%v is quarantining %v until %v\n", node.Address(), address, entry.until)
+		}
+	}
+	node.quarantine.entries[address] = entry
+}
+
+// expireUnsafe removes the entries of peers that are not quarantined, and have
+// not offended within the duration. It assumes that the mutex is held.
+func (quarantine *quarantine) expireUnsafe(now time.Time, duration time.Duration) {
+	for address, entry := range quarantine.entries {
+		if !now.Before(entry.until) && now.Sub(entry.last) >= duration {
+			delete(quarantine.entries, address)
+		}
+	}
+}
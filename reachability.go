@@ -0,0 +1,106 @@
+package swarm
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+)
+
+// A reachabilityProbes tracks the peers that are being pinged to verify that
+// they are reachable, so that a peer that sends many RPCs is only pinged once
+// at a time.
+type reachabilityProbes struct {
+	mu      *sync.Mutex
+	pending map[identity.Address]struct{}
+}
+
+func newReachabilityProbes() *reachabilityProbes {
+	return &reachabilityProbes{
+		mu:      new(sync.Mutex),
+		pending: map[identity.Address]struct{}{},
+	}
+}
+
+// addReachablePeer adds a peer that contacted the Node to the dht.DHT. When
+// the VerifyReachability option is set, or the ProbationPings option is
+// positive, a peer that is not already in the dht.DHT is put on probation. It
+// is pinged in the background, and is only added if every ping succeeds. At
+// most MaxPendingPeers peers are on probation at the same time, and new peers
+// are not put on probation while maintenance is paused.
+func (node *Node) addReachablePeer(multiAddress identity.MultiAddress) error {
+	options := node.options()
+	if !options.VerifyReachability && options.ProbationPings <= 0 {
+		return node.addPeer(multiAddress)
+	}
+	current, err := node.findMultiAddress(multiAddress.Address())
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		return node.addPeer(multiAddress)
+	}
+
+	if node.MaintenancePaused() {
+		return nil
+	}
+
+	node.probes.mu.Lock()
+	if _, ok := node.probes.pending[multiAddress.Address()]; ok || len(node.probes.pending) >= MaxPendingPeers {
+		node.probes.mu.Unlock()
+		return nil
+	}
+	node.probes.pending[multiAddress.Address()] = struct{}{}
+	node.probes.mu.Unlock()
+
+	go func() {
+		defer func() {
+			node.probes.mu.Lock()
+			delete(node.probes.pending, multiAddress.Address())
+			node.probes.mu.Unlock()
+		}()
+
+		if !node.probation(multiAddress) {
+			return
+		}
+		if err := node.addPeer(multiAddress); err != nil && options.Debug >= DebugLow {
+			log.Println(err)
+		}
+	}()
+	return nil
+}
+
+// probation pings a peer ProbationPings times, at least once, spread evenly
+// over the ProbationDuration. Returns true if every ping succeeded, otherwise
+// false.
+func (node *Node) probation(multiAddress identity.MultiAddress) bool {
+	options := node.options()
+	pings := options.ProbationPings
+	if pings < 1 {
+		pings = 1
+	}
+	interval := options.ProbationDuration / time.Duration(pings)
+	for i := 0; i < pings; i++ {
+		if i > 0 && interval > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-timer.C:
+			case <-node.done:
+				timer.Stop()
+				return false
+			}
+		}
+		ctx, cancel := context.WithTimeout(WithPriority(context.Background(), PriorityLow), options.Timeout)
+		err := node.pingTarget(ctx, multiAddress)
+		cancel()
+		if err != nil {
+			if options.Debug >= DebugLow {
+				log.Printf("%v cannot reach %v: %v\n", node.Address(), multiAddress.Address(), err)
+			}
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package swarm_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-rpc"
+	"github.com/republicprotocol/go-swarm-network"
+)
+
+var _ = Describe("Reachability", func() {
+
+	contains := func(node, peer *swarm.Node) func() bool {
+		return func() bool {
+			return node.Contains(peer.Address())
+		}
+	}
+
+	Context("when verifying the reachability of new peers", func() {
+
+		It("should add peers that can be pinged back", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.VerifyReachability = true
+			stop, err := ServeNodes(nodes)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			Ω(rpc.PingTarget(nodes[0].MultiAddress(), nodes[1].MultiAddress(), time.Second)).ShouldNot(HaveOccurred())
+			Eventually(contains(nodes[0], nodes[1]), time.Second).Should(BeTrue())
+		})
+
+		It("should not add peers that cannot be pinged back", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.VerifyReachability = true
+			nodes[0].Options.Timeout = 200 * time.Millisecond
+			stop, err := ServeNodes(nodes[:1])
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			Ω(rpc.PingTarget(nodes[0].MultiAddress(), nodes[1].MultiAddress(), time.Second)).ShouldNot(HaveOccurred())
+			Consistently(contains(nodes[0], nodes[1]), 500*time.Millisecond).Should(BeFalse())
+		})
+	})
+
+	Context("when new peers are on probation", func() {
+
+		It("should add peers once they pass every ping", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.ProbationPings = 3
+			nodes[0].Options.ProbationDuration = 600 * time.Millisecond
+			stop, err := ServeNodes(nodes)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer stop()
+
+			Ω(rpc.PingTarget(nodes[0].MultiAddress(), nodes[1].MultiAddress(), time.Second)).ShouldNot(HaveOccurred())
+			Consistently(contains(nodes[0], nodes[1]), 200*time.Millisecond).Should(BeFalse())
+			Eventually(contains(nodes[0], nodes[1]), 2*time.Second).Should(BeTrue())
+		})
+
+		It("should not add peers that fail a ping", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.ProbationPings = 3
+			nodes[0].Options.ProbationDuration = 600 * time.Millisecond
+			nodes[0].Options.Timeout = 200 * time.Millisecond
+			stop, err := ServeNodes(nodes)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			// The peer goes away after the first ping of its probation.
+			Ω(rpc.PingTarget(nodes[0].MultiAddress(), nodes[1].MultiAddress(), time.Second)).ShouldNot(HaveOccurred())
+			time.Sleep(100 * time.Millisecond)
+			nodes[1].Server.Stop()
+			defer stop()
+			Consistently(contains(nodes[0], nodes[1]), 1500*time.Millisecond).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,109 @@
+package swarm
+
+import (
+	"log"
+	"sync"
+
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+)
+
+// A replacementCache stores peers that could not be added to the dht.DHT
+// because their bucket was full. When a peer is removed from a bucket, the
+// most recently seen replacement for that bucket that is alive takes its
+// place.
+type replacementCache struct {
+	mu      *sync.Mutex
+	buckets map[int]identity.MultiAddresses
+}
+
+func newReplacementCache() *replacementCache {
+	return &replacementCache{
+		mu:      new(sync.Mutex),
+		buckets: map[int]identity.MultiAddresses{},
+	}
+}
+
+// put stores an identity.MultiAddress as the most recently seen replacement
+// for a bucket. The least recently seen replacement is dropped when the bucket
+// has more than capacity replacements.
+func (cache *replacementCache) put(bucket int, multiAddress identity.MultiAddress, capacity int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	replacements := make(identity.MultiAddresses, 0, len(cache.buckets[bucket])+1)
+	for _, replacement := range cache.buckets[bucket] {
+		if replacement.Address() != multiAddress.Address() {
+			replacements = append(replacements, replacement)
+		}
+	}
+	replacements = append(replacements, multiAddress)
+	if capacity > 0 && len(replacements) > capacity {
+		replacements = replacements[len(replacements)-capacity:]
+	}
+	cache.buckets[bucket] = replacements
+}
+
+// pop removes and returns the most recently seen replacement for a bucket.
+func (cache *replacementCache) pop(bucket int) (identity.MultiAddress, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	replacements := cache.buckets[bucket]
+	if len(replacements) == 0 {
+		return identity.MultiAddress{}, false
+	}
+	replacement := replacements[len(replacements)-1]
+	cache.buckets[bucket] = replacements[:len(replacements)-1]
+	return replacement, true
+}
+
+// storeReplacement stores an identity.MultiAddress that could not be added to
+// its full bucket in the replacement cache.
+func (node *Node) storeReplacement(multiAddress identity.MultiAddress) error {
+	bucket, err := node.Address().SamePrefixLength(multiAddress.Address())
+	if err != nil {
+		return err
+	}
+	node.replacements.put(bucket, multiAddress, node.options().MaxBucketLength)
+	return nil
+}
+
+// promoteReplacement adds the most recently seen replacement for the bucket
+// of an identity.Address that was removed from the dht.DHT. Each replacement
+// is pinged before it is added, and replacements that do not respond are
+// dropped in favor of the next one, so that promotion never adds a dead peer.
+// It must not be called while holding the dhtMu, because it makes RPCs.
+func (node *Node) promoteReplacement(removed identity.Address) {
+	options := node.options()
+	bucket, err := node.Address().SamePrefixLength(removed)
+	if err != nil {
+		return
+	}
+	for {
+		select {
+		case <-node.done:
+			return
+		default:
+		}
+		// The replacements stay in the cache, and are promoted the next time
+		// that a peer is removed from the bucket.
+		if node.MaintenancePaused() {
+			return
+		}
+		replacement, ok := node.replacements.pop(bucket)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(WithPriority(context.Background(), PriorityLow), options.Timeout)
+		err := node.pingTarget(ctx, replacement)
+		cancel()
+		if err != nil {
+			if options.Debug >= DebugMedium {
+				log.Printf("%v is dropping dead replacement %v: %v\n", node.Address(), replacement, err)
+			}
+			continue
+		}
+		if err := node.updateMultiAddress(replacement); err == nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package swarm
+
+import (
+	"log"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// resourceDialer returns a dialer for the grpc.WithDialer option that sets the
+// flag when a connection cannot be opened because the Node has run out of
+// file descriptors.
+func resourceDialer(exhausted *int32) func(string, time.Duration) (net.Conn, error) {
+	return func(address string, timeout time.Duration) (net.Conn, error) {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil && isResourceError(err) {
+			atomic.StoreInt32(exhausted, 1)
+		}
+		return conn, err
+	}
+}
+
+// recordResourceError counts an outbound connection that failed because the
+// Node has run out of file descriptors, and returns ErrResourceExhausted.
+func (node *Node) recordResourceError() error {
+	atomic.AddUint64(&node.resourceErrors, 1)
+	if node.options().Debug >= DebugLow {
+		log.Printf("%v cannot open connections: %v\n", node.Address(), ErrResourceExhausted)
+	}
+	return ErrResourceExhausted
+}
+
+// isResourceError returns true if the error was caused by the process, or the
+// system, running out of file descriptors.
+func isResourceError(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if syscallErr, ok := err.(*os.SyscallError); ok {
+		err = syscallErr.Err
+	}
+	errno, ok := err.(syscall.Errno)
+	return ok && (errno == syscall.EMFILE || errno == syscall.ENFILE)
+}
@@ -0,0 +1,165 @@
+package swarm
+
+import (
+	"bytes"
+
+	"github.com/republicprotocol/go-identity"
+	"golang.org/x/net/context"
+)
+
+// Owner returns the identity.MultiAddress that owns the key on a consistent
+// hashing ring. The ring is made from the identity.IDs of the peers in the
+// dht.DHT, and the Node itself. The owner is the first identity.MultiAddress
+// at, or after, the key on the ring, wrapping around to the lowest
+// identity.ID when the key is after every identity.MultiAddress. Unlike
+// lookups, this does not use the XOR distance, and the result only depends on
+// the peers known to the Node.
+func (node *Node) Owner(key identity.Address) (identity.MultiAddress, error) {
+	keyID := key.ID()
+	if len(keyID) == 0 {
+		return identity.MultiAddress{}, ErrInvalidAddress
+	}
+
+	owner, first := node.MultiAddress(), node.MultiAddress()
+	ownerID, firstID := node.Address().ID(), node.Address().ID()
+	if bytes.Compare(ownerID, keyID) < 0 {
+		ownerID = nil
+	}
+	for _, multiAddress := range node.DHT.MultiAddresses() {
+		id := multiAddress.Address().ID()
+		if len(id) == 0 {
+			continue
+		}
+		if bytes.Compare(id, firstID) < 0 {
+			first, firstID = multiAddress, id
+		}
+		if bytes.Compare(id, keyID) >= 0 && (ownerID == nil || bytes.Compare(id, ownerID) < 0) {
+			owner, ownerID = multiAddress, id
+		}
+	}
+
+	// No identity.MultiAddress is at, or after, the key so the ring wraps
+	// around to the lowest identity.ID.
+	if ownerID == nil {
+		return first, nil
+	}
+	return owner, nil
+}
+
+// ClosestPreceding returns the peer in the dht.DHT whose identity.ID is the
+// largest identity.ID that is strictly less than the key, wrapping around to
+// the largest identity.ID when no identity.ID is less than the key. This is
+// the selection used by Chord-style finger routing. Returns nil if there are
+// no peers in the dht.DHT.
+func (node *Node) ClosestPreceding(key identity.Address) (*identity.MultiAddress, error) {
+	keyID := key.ID()
+	if len(keyID) == 0 {
+		return nil, ErrInvalidAddress
+	}
+
+	var preceding, last *identity.MultiAddress
+	var precedingID, lastID identity.ID
+	for _, multiAddress := range node.DHT.MultiAddresses() {
+		multiAddress := multiAddress
+		id := multiAddress.Address().ID()
+		if len(id) == 0 {
+			continue
+		}
+		if last == nil || bytes.Compare(id, lastID) > 0 {
+			last, lastID = &multiAddress, id
+		}
+		if bytes.Compare(id, keyID) < 0 && (preceding == nil || bytes.Compare(id, precedingID) > 0) {
+			preceding, precedingID = &multiAddress, id
+		}
+	}
+
+	// No identity.MultiAddress is before the key so the ring wraps around to
+	// the highest identity.ID.
+	if preceding == nil {
+		return last, nil
+	}
+	return preceding, nil
+}
+
+// ResponsibilityRange returns the arc of the ring that the Node owns, using
+// the same ownership as Owner. The arc starts just after the identity.ID of
+// the closest preceding peer, and ends at the identity.ID of the Node, both
+// inclusive. The arc wraps around the ring when the start is after the end.
+// When there are no peers in the dht.DHT, the Node owns the whole ring.
+func (node *Node) ResponsibilityRange() (start, end identity.Address, err error) {
+	end = node.Address()
+	preceding, err := node.ClosestPreceding(end)
+	if err != nil {
+		return "", "", err
+	}
+	startID := end.ID()
+	if preceding != nil {
+		startID = preceding.Address().ID()
+	}
+	return incrementID(startID).Address(), end, nil
+}
+
+// A ResponsibilityRangeEvent is emitted when the ResponsibilityRange of a
+// Node changes.
+type ResponsibilityRangeEvent struct {
+	Start identity.Address
+	End   identity.Address
+}
+
+// SubscribeResponsibilityRange returns a channel of
+// ResponsibilityRangeEvents. The current ResponsibilityRange is sent first,
+// and afterwards a ResponsibilityRangeEvent is sent whenever a change to the
+// dht.DHT changes the ResponsibilityRange. The channel is closed when the
+// context is done, or when the Node is closed.
+func (node *Node) SubscribeResponsibilityRange(ctx context.Context) <-chan ResponsibilityRangeEvent {
+	events := make(chan ResponsibilityRangeEvent)
+	go func() {
+		defer close(events)
+
+		var last *ResponsibilityRangeEvent
+		for {
+			node.dhtMu.RLock()
+			changed := node.dhtChanged
+			node.dhtMu.RUnlock()
+			start, end, err := node.ResponsibilityRange()
+			if err != nil {
+				return
+			}
+
+			event := ResponsibilityRangeEvent{Start: start, End: end}
+			if last == nil || *last != event {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				case <-node.done:
+					return
+				}
+				last = &event
+			}
+
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			case <-node.done:
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// incrementID returns a copy of an identity.ID plus one, wrapping around to
+// zero after the largest identity.ID.
+func incrementID(id identity.ID) identity.ID {
+	incremented := make(identity.ID, len(id))
+	copy(incremented, id)
+	for i := len(incremented) - 1; i >= 0; i-- {
+		incremented[i]++
+		if incremented[i] != 0 {
+			break
+		}
+	}
+	return incremented
+}
@@ -0,0 +1,81 @@
+package swarm_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-swarm-network"
+)
+
+var _ = Describe("Consistent hashing ring", func() {
+
+	var node, peer *swarm.Node
+
+	BeforeEach(func() {
+		nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+		Ω(err).ShouldNot(HaveOccurred())
+		node, peer = nodes[0], nodes[1]
+	})
+
+	Context("when finding the owner of a key", func() {
+
+		It("should own every key when it has no peers", func() {
+			owner, err := node.Owner(peer.Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(owner.String()).Should(Equal(node.MultiAddress().String()))
+		})
+
+		It("should return the owner of its own address", func() {
+			Ω(node.DHT.UpdateMultiAddress(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			owner, err := node.Owner(node.Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(owner.String()).Should(Equal(node.MultiAddress().String()))
+		})
+
+		It("should wrap around to the lowest address", func() {
+			Ω(node.DHT.UpdateMultiAddress(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			lowest, highest := node, peer
+			if bytes.Compare(peer.Address().ID(), node.Address().ID()) < 0 {
+				lowest, highest = peer, node
+			}
+			key := append(highest.Address().ID(), 0xFF).Address()
+			owner, err := node.Owner(key)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(owner.String()).Should(Equal(lowest.MultiAddress().String()))
+		})
+	})
+
+	Context("when finding the closest preceding peer of a key", func() {
+
+		It("should return nothing when it has no peers", func() {
+			preceding, err := node.ClosestPreceding(peer.Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(preceding).Should(BeNil())
+		})
+
+		It("should wrap around to the highest address", func() {
+			Ω(node.DHT.UpdateMultiAddress(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			preceding, err := node.ClosestPreceding(peer.Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(preceding).ShouldNot(BeNil())
+			Ω(preceding.String()).Should(Equal(peer.MultiAddress().String()))
+		})
+	})
+
+	Context("when computing the responsibility range", func() {
+
+		It("should own both ends of its range", func() {
+			Ω(node.DHT.UpdateMultiAddress(peer.MultiAddress())).ShouldNot(HaveOccurred())
+			start, end, err := node.ResponsibilityRange()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(end).Should(Equal(node.Address()))
+			for _, key := range []identity.Address{start, end} {
+				owner, err := node.Owner(key)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(owner.String()).Should(Equal(node.MultiAddress().String()))
+			}
+		})
+	})
+})
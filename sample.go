@@ -0,0 +1,108 @@
+package swarm
+
+import (
+	"github.com/republicprotocol/go-identity"
+)
+
+// Constants for the different ways that peers can be sampled by SamplePeers.
+// An empty strategy is equivalent to SampleUniform.
+const (
+	SampleUniform          = "uniform"
+	SampleOnePerBucket     = "one-per-bucket"
+	SampleDistanceWeighted = "distance-weighted"
+)
+
+// SamplePeers returns a random sample of up to n peers from the dht.DHT, for
+// disseminating messages rather than routing them. SampleUniform samples
+// every peer with the same probability. SampleOnePerBucket samples one peer
+// from each bucket, starting with the bucket that is furthest from the Node,
+// before sampling a second peer from any bucket, which spreads the sample
+// across the whole keyspace. SampleDistanceWeighted samples each bucket with
+// the same probability, no matter how many peers it holds, so that the many
+// peers in far buckets do not drown out the few peers in near buckets. The
+// RandomSource option is used, so that samples are reproducible.
+func (node *Node) SamplePeers(n int, strategy string) identity.MultiAddresses {
+	peers := node.DHT.MultiAddresses()
+	if n <= 0 || len(peers) == 0 {
+		return identity.MultiAddresses{}
+	}
+	node.randomMu.Lock()
+	for i := len(peers) - 1; i > 0; i-- {
+		j := node.random.Intn(i + 1)
+		peers[i], peers[j] = peers[j], peers[i]
+	}
+	node.randomMu.Unlock()
+
+	switch strategy {
+	case SampleOnePerBucket:
+		return node.sampleOnePerBucket(peers, n)
+	case SampleDistanceWeighted:
+		return node.sampleDistanceWeighted(peers, n)
+	default:
+		return truncate(peers, n)
+	}
+}
+
+// sampleOnePerBucket takes one peer from each bucket in turn, from the
+// furthest bucket to the nearest, until n peers have been taken. The peers
+// must already be shuffled.
+func (node *Node) sampleOnePerBucket(peers identity.MultiAddresses, n int) identity.MultiAddresses {
+	buckets := node.groupByBucket(peers)
+	sample := make(identity.MultiAddresses, 0, n)
+	for taken := true; taken && len(sample) < n; {
+		taken = false
+		for prefix := range buckets {
+			if len(sample) == n {
+				break
+			}
+			if len(buckets[prefix]) == 0 {
+				continue
+			}
+			sample = append(sample, buckets[prefix][0])
+			buckets[prefix] = buckets[prefix][1:]
+			taken = true
+		}
+	}
+	return sample
+}
+
+// sampleDistanceWeighted takes n peers, without replacement, by picking a
+// random non-empty bucket, and then the next peer in that bucket. The peers
+// must already be shuffled.
+func (node *Node) sampleDistanceWeighted(peers identity.MultiAddresses, n int) identity.MultiAddresses {
+	buckets := node.groupByBucket(peers)
+	nonEmpty := make([]int, 0, len(buckets))
+	for prefix := range buckets {
+		if len(buckets[prefix]) > 0 {
+			nonEmpty = append(nonEmpty, prefix)
+		}
+	}
+
+	sample := make(identity.MultiAddresses, 0, n)
+	for len(sample) < n && len(nonEmpty) > 0 {
+		node.randomMu.Lock()
+		i := node.random.Intn(len(nonEmpty))
+		node.randomMu.Unlock()
+		prefix := nonEmpty[i]
+		sample = append(sample, buckets[prefix][0])
+		buckets[prefix] = buckets[prefix][1:]
+		if len(buckets[prefix]) == 0 {
+			nonEmpty = append(nonEmpty[:i], nonEmpty[i+1:]...)
+		}
+	}
+	return sample
+}
+
+// groupByBucket groups peers by the number of prefix bits that they share with
+// the Node, preserving their order. Peers that cannot be compared are dropped.
+func (node *Node) groupByBucket(peers identity.MultiAddresses) []identity.MultiAddresses {
+	buckets := make([]identity.MultiAddresses, identity.IDLength*8)
+	for _, peer := range peers {
+		prefix, err := node.Address().SamePrefixLength(peer.Address())
+		if err != nil || prefix < 0 || prefix >= len(buckets) {
+			continue
+		}
+		buckets[prefix] = append(buckets[prefix], peer)
+	}
+	return buckets
+}
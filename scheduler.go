@@ -0,0 +1,121 @@
+package swarm
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Constants for the priorities of outbound RPCs. When the MaxOutboundRate
+// option limits outbound RPCs, RPCs with PriorityLow wait until no RPCs with
+// PriorityHigh are waiting. RPCs have PriorityHigh unless their context was
+// created using WithPriority. The Node uses PriorityLow for maintenance, such
+// as pruning and verifying peers.
+const (
+	PriorityHigh = 0
+	PriorityLow  = 1
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context that gives the outbound RPCs that use it a
+// priority.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// priorityFromContext returns the priority of a context, or PriorityHigh if it
+// does not have one.
+func priorityFromContext(ctx context.Context) int {
+	if priority, ok := ctx.Value(priorityKey{}).(int); ok && priority == PriorityLow {
+		return PriorityLow
+	}
+	return PriorityHigh
+}
+
+// schedule waits until an outbound RPC can be made without exceeding the
+// MaxOutboundRate option, using the priority of the context. It must be called
+// once before each outbound RPC, including RPCs that share a connection.
+func (node *Node) schedule(ctx context.Context) error {
+	return node.scheduler.wait(ctx, priorityFromContext(ctx), node.options().MaxOutboundRate)
+}
+
+// An outboundScheduler limits the rate of outbound RPCs using a token bucket
+// that holds up to one second of tokens. Waiting RPCs with a higher priority
+// are given tokens first.
+type outboundScheduler struct {
+	mu      *sync.Mutex
+	tokens  float64
+	last    time.Time
+	waiting [2]int
+}
+
+func newOutboundScheduler() *outboundScheduler {
+	return &outboundScheduler{
+		mu: new(sync.Mutex),
+	}
+}
+
+// wait blocks until an RPC with the priority can be made without exceeding
+// the rate, in RPCs per second, or until the context is done. A rate that is
+// not positive does not limit RPCs.
+func (scheduler *outboundScheduler) wait(ctx context.Context, priority int, rate float64) error {
+	if rate <= 0 {
+		return nil
+	}
+	registered := false
+	defer func() {
+		if registered {
+			scheduler.mu.Lock()
+			scheduler.waiting[priority]--
+			scheduler.mu.Unlock()
+		}
+	}()
+
+	for {
+		scheduler.mu.Lock()
+		now := time.Now()
+		if scheduler.last.IsZero() {
+			scheduler.tokens = rate
+		} else {
+			scheduler.tokens += now.Sub(scheduler.last).Seconds() * rate
+		}
+		if burst := maxFloat(rate, 1); scheduler.tokens > burst {
+			scheduler.tokens = burst
+		}
+		scheduler.last = now
+
+		if scheduler.tokens >= 1 && (priority == PriorityHigh || scheduler.waiting[PriorityHigh] == 0) {
+			scheduler.tokens--
+			scheduler.mu.Unlock()
+			return nil
+		}
+		if !registered {
+			scheduler.waiting[priority]++
+			registered = true
+		}
+		delay := time.Duration((1 - scheduler.tokens) / rate * float64(time.Second))
+		if delay <= 0 {
+			// Tokens are available, but are reserved for RPCs with a higher
+			// priority.
+			delay = time.Duration(float64(time.Second) / rate)
+		}
+		scheduler.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func maxFloat(x, y float64) float64 {
+	if x > y {
+		return x
+	}
+	return y
+}
@@ -0,0 +1,74 @@
+package swarm_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-swarm-network"
+	"golang.org/x/net/context"
+)
+
+var _ = Describe("Outbound scheduling", func() {
+
+	// setupScheduler returns a Node that limits its outbound RPCs to the rate,
+	// and a peer that is being served.
+	setupScheduler := func(rate float64) (*swarm.Node, *swarm.Node, func()) {
+		nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+		Ω(err).ShouldNot(HaveOccurred())
+		nodes[0].Options.MaxOutboundRate = rate
+		stop, err := ServeNodes(nodes[1:])
+		Ω(err).ShouldNot(HaveOccurred())
+		return nodes[0], nodes[1], stop
+	}
+
+	Context("when limiting the rate of outbound RPCs", func() {
+
+		It("should charge each RPC that shares a connection", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			node, peer, stop := setupScheduler(5)
+			defer stop()
+
+			// The first five queries use the burst, and the other five wait
+			// for a fifth of a second each.
+			targets := make([]identity.Address, 10)
+			for i := range targets {
+				targets[i] = node.RandomAddress(i)
+			}
+			begin := time.Now()
+			_, err := node.QueryCloserPeersForTargets(context.Background(), peer.MultiAddress(), targets)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(time.Since(begin)).Should(BeNumerically(">=", 800*time.Millisecond))
+		})
+	})
+
+	Context("when RPCs with different priorities are waiting", func() {
+
+		It("should give tokens to RPCs with a high priority first", func() {
+			testMu.Lock()
+			defer testMu.Unlock()
+
+			node, peer, stop := setupScheduler(1)
+			defer stop()
+
+			// Spend the burst, so that the next RPCs have to wait.
+			node.QueryPeerInfo(context.Background(), peer.MultiAddress())
+
+			done := make(chan int, 2)
+			go func() {
+				node.QueryPeerInfo(swarm.WithPriority(context.Background(), swarm.PriorityLow), peer.MultiAddress())
+				done <- swarm.PriorityLow
+			}()
+			time.Sleep(100 * time.Millisecond)
+			go func() {
+				node.QueryPeerInfo(context.Background(), peer.MultiAddress())
+				done <- swarm.PriorityHigh
+			}()
+			Eventually(done, 3*time.Second).Should(Receive(Equal(swarm.PriorityHigh)))
+			Eventually(done, 3*time.Second).Should(Receive(Equal(swarm.PriorityLow)))
+		})
+	})
+})
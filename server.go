@@ -0,0 +1,39 @@
+package swarm
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Keepalive parameters used by DefaultServer. Connections are pinged after
+// DefaultKeepaliveTime without activity, and are closed if the ping is not
+// acknowledged within DefaultKeepaliveTimeout. Connections that are idle for
+// longer than DefaultMaxConnectionIdle are closed.
+const (
+	DefaultKeepaliveTime     = 30 * time.Second
+	DefaultKeepaliveTimeout  = 10 * time.Second
+	DefaultMaxConnectionIdle = 5 * time.Minute
+)
+
+// DefaultServer returns a grpc.Server with keepalive parameters that are
+// suitable for a Node. Dead connections, and the streams that use them, are
+// detected by keepalive pings instead of lingering until the TCP connection
+// times out. Clients are allowed to ping without active streams, but not more
+// often than half the DefaultKeepaliveTime. Additional grpc.ServerOptions,
+// such as those returned by Options.ServerOptions, are appended.
+func DefaultServer(serverOptions ...grpc.ServerOption) *grpc.Server {
+	defaults := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle: DefaultMaxConnectionIdle,
+			Time:              DefaultKeepaliveTime,
+			Timeout:           DefaultKeepaliveTimeout,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             DefaultKeepaliveTime / 2,
+			PermitWithoutStream: true,
+		}),
+	}
+	return grpc.NewServer(append(defaults, serverOptions...)...)
+}
@@ -0,0 +1,91 @@
+package swarm
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+)
+
+// nodeState is the serialized state of a Node that is handed off to a
+// replacement Node.
+type nodeState struct {
+	Peers []peerState `json:"peers"`
+}
+
+type peerState struct {
+	MultiAddress string            `json:"multiAddress"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	RTT          time.Duration     `json:"rtt,omitempty"`
+}
+
+// ExportState serializes the peers in the dht.DHT, in the order that they
+// were last updated, together with their metadata and round trip times. A
+// replacement Node, created with the same MultiAddress option, can use
+// ImportState to start with the same routing table.
+func (node *Node) ExportState() ([]byte, error) {
+	node.dhtMu.RLock()
+	defer node.dhtMu.RUnlock()
+	node.peersMu.RLock()
+	defer node.peersMu.RUnlock()
+
+	multiAddresses := node.DHT.MultiAddresses()
+	sort.SliceStable(multiAddresses, func(i, j int) bool {
+		return node.sequenceUnsafe(multiAddresses[i].Address()) < node.sequenceUnsafe(multiAddresses[j].Address())
+	})
+	state := nodeState{Peers: make([]peerState, 0, len(multiAddresses))}
+	for _, multiAddress := range multiAddresses {
+		peerState := peerState{MultiAddress: EncodeMultiAddress(multiAddress)}
+		if p, ok := node.peers[multiAddress.Address()]; ok {
+			peerState.Metadata = copyMetadata(p.metadata)
+			peerState.RTT = p.rtt
+		}
+		state.Peers = append(state.Peers, peerState)
+	}
+	return json.Marshal(state)
+}
+
+// ImportState adds the peers serialized by ExportState to the dht.DHT, and
+// restores their metadata and round trip times. Peers that cannot be added,
+// including peers in a full bucket, are skipped. No peers are pruned, so the
+// import does not use the network.
+func (node *Node) ImportState(data []byte) error {
+	state := nodeState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	node.dhtMu.Lock()
+	defer node.dhtMu.Unlock()
+	for _, peerState := range state.Peers {
+		multiAddress, err := DecodeMultiAddress(peerState.MultiAddress)
+		if err != nil {
+			continue
+		}
+		if err := node.checkPeer(multiAddress); err != nil {
+			continue
+		}
+		if err := node.updateMultiAddressUnsafe(multiAddress); err != nil {
+			continue
+		}
+
+		node.restorePeerUnsafe(multiAddress.Address(), peerState)
+	}
+	return nil
+}
+
+// restorePeerUnsafe restores the metadata and round trip time of a peer, if it
+// is in the dht.DHT. The dhtMu must be locked.
+func (node *Node) restorePeerUnsafe(address identity.Address, peerState peerState) {
+	multiAddress, err := node.findMultiAddress(address)
+	if err != nil || multiAddress == nil {
+		return
+	}
+
+	node.peersMu.Lock()
+	defer node.peersMu.Unlock()
+	p := node.peer(address)
+	p.metadata = copyMetadata(peerState.Metadata)
+	p.rtt = peerState.RTT
+}
@@ -0,0 +1,86 @@
+package swarm
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// StatsPeersMetadataKey and StatsBucketsMetadataKey are the gRPC headers that
+// are sent in response to a ping when the ExposeStats option is set. They
+// contain the number of peers in the dht.DHT, and the comma separated length
+// of each bucket.
+const (
+	StatsPeersMetadataKey   = "swarm-stats-peers"
+	StatsBucketsMetadataKey = "swarm-stats-buckets"
+)
+
+// PeerStats is the occupancy of the dht.DHT reported by a peer.
+type PeerStats struct {
+	NumberOfPeers int
+
+	// BucketLengths is the number of peers that share each number of prefix
+	// bits with the peer.
+	BucketLengths []int
+}
+
+// QueryStats pings a peer and returns the occupancy of its dht.DHT, so that a
+// monitoring tool can crawl the network and aggregate the health of routing
+// tables. Returns ErrStatsUnavailable if the peer does not expose its stats.
+func (node *Node) QueryStats(ctx context.Context, target identity.MultiAddress) (PeerStats, error) {
+	ctx = node.outbound(ctx, MethodPing, target)
+	if err := node.schedule(ctx); err != nil {
+		return PeerStats{}, err
+	}
+	conn, err := node.dial(ctx, target)
+	if err != nil {
+		return PeerStats{}, err
+	}
+	defer conn.Close()
+
+	header := metadata.MD{}
+	if _, err := rpc.NewSwarmNodeClient(conn).Ping(ctx, rpc.SerializeMultiAddress(node.MultiAddress()), grpc.Header(&header)); err != nil {
+		return PeerStats{}, err
+	}
+	return parseStats(header)
+}
+
+// statsHeader returns the gRPC header that reports the occupancy of the
+// dht.DHT in response to a ping, or nil if the ExposeStats option is not set.
+func (node *Node) statsHeader() metadata.MD {
+	if !node.options().ExposeStats {
+		return nil
+	}
+	info := node.Info()
+	bucketLengths := make([]string, len(info.BucketLengths))
+	for i, length := range info.BucketLengths {
+		bucketLengths[i] = strconv.Itoa(length)
+	}
+	return metadata.Pairs(
+		StatsPeersMetadataKey, strconv.Itoa(info.NumberOfPeers),
+		StatsBucketsMetadataKey, strings.Join(bucketLengths, ","),
+	)
+}
+
+func parseStats(header metadata.MD) (PeerStats, error) {
+	if len(header[StatsPeersMetadataKey]) == 0 || len(header[StatsBucketsMetadataKey]) == 0 {
+		return PeerStats{}, ErrStatsUnavailable
+	}
+	numberOfPeers, err := strconv.Atoi(header[StatsPeersMetadataKey][0])
+	if err != nil {
+		return PeerStats{}, ErrStatsUnavailable
+	}
+	fields := strings.Split(header[StatsBucketsMetadataKey][0], ",")
+	bucketLengths := make([]int, len(fields))
+	for i, field := range fields {
+		if bucketLengths[i], err = strconv.Atoi(field); err != nil {
+			return PeerStats{}, ErrStatsUnavailable
+		}
+	}
+	return PeerStats{NumberOfPeers: numberOfPeers, BucketLengths: bucketLengths}, nil
+}
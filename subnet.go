@@ -0,0 +1,57 @@
+package swarm
+
+import (
+	"net"
+
+	"github.com/republicprotocol/go-identity"
+)
+
+// subnet returns the /24 subnet of an IPv4 identity.MultiAddress, or the /48
+// subnet of an IPv6 identity.MultiAddress. Returns false if the
+// identity.MultiAddress does not use an IP address, such as when it uses a DNS
+// name.
+func subnet(multiAddress identity.MultiAddress) (string, bool) {
+	address, err := dialAddress(multiAddress)
+	if err != nil {
+		return "", false
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String(), true
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String(), true
+}
+
+// checkSubnetUnsafe returns ErrSubnetLimit if adding an identity.MultiAddress
+// to the dht.DHT would exceed the MaxPeersPerSubnet option. Peers that are
+// already in the dht.DHT can always be updated. The dhtMu must be locked.
+func (node *Node) checkSubnetUnsafe(multiAddress identity.MultiAddress) error {
+	options := node.options()
+	if options.MaxPeersPerSubnet <= 0 {
+		return nil
+	}
+	key, ok := subnet(multiAddress)
+	if !ok {
+		return nil
+	}
+	count := 0
+	for _, peer := range node.DHT.MultiAddresses() {
+		if peer.Address() == multiAddress.Address() {
+			return nil
+		}
+		if peerKey, ok := subnet(peer); ok && peerKey == key {
+			count++
+		}
+	}
+	if count >= options.MaxPeersPerSubnet {
+		return ErrSubnetLimit
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package swarm_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-swarm-network"
+)
+
+var _ = Describe("Subnet diversity", func() {
+
+	Context("when the subnet limit is reached", func() {
+
+		It("should reject new peers from the same subnet", func() {
+			nodes, err := GenerateNodes(NodePortSwarm, 3, newMockDelegate())
+			Ω(err).ShouldNot(HaveOccurred())
+			nodes[0].Options.MaxPeersPerSubnet = 1
+
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].AddPeer(nodes[1].MultiAddress())).ShouldNot(HaveOccurred())
+			Ω(nodes[0].AddPeer(nodes[2].MultiAddress())).Should(Equal(swarm.ErrSubnetLimit))
+			Ω(nodes[0].DHT.MultiAddresses()).Should(HaveLen(1))
+		})
+	})
+})
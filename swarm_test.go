@@ -3,6 +3,8 @@ package swarm_test
 import (
 	"fmt"
 	"math/rand"
+	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -24,6 +26,7 @@ const (
 const (
 	NodePortBootstrap = 3000
 	NodePortSwarm     = 4000
+	NodePortFrontier  = 5000
 )
 
 func GenerateBootstrapTopology(topology Topology, numberOfNodes int, delegate swarm.Delegate) ([]*swarm.Node, map[identity.Address][]*swarm.Node, error) {
@@ -73,6 +76,91 @@ func GenerateNodes(port, numberOfNodes int, delegate swarm.Delegate) ([]*swarm.N
 	return nodes, nil
 }
 
+// Port returns the port that GenerateNodes gave to a node.
+func Port(node *swarm.Node) int {
+	port := 0
+	fmt.Sscanf(node.MultiAddress().String(), "/ip4/127.0.0.1/tcp/%d/", &port)
+	return port
+}
+
+// ServeNodes serves each of the nodes on the port that GenerateNodes gave it,
+// and returns a function that stops them.
+func ServeNodes(nodes []*swarm.Node) (func(), error) {
+	for i, node := range nodes {
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", Port(node)))
+		if err != nil {
+			for _, node := range nodes[:i] {
+				node.Server.Stop()
+			}
+			return nil, err
+		}
+		node.Register()
+		go node.Server.Serve(listener)
+	}
+	return func() {
+		for _, node := range nodes {
+			node.Server.Stop()
+		}
+	}, nil
+}
+
+// SilentListener accepts connections but never responds to them, so that RPCs
+// sent to it hang until their deadline.
+type SilentListener struct {
+	listener net.Listener
+
+	mu    *sync.Mutex
+	conns []net.Conn
+}
+
+// ListenSilently starts a SilentListener in place of a node, on the port that
+// GenerateNodes gave it.
+func ListenSilently(node *swarm.Node) (*SilentListener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", Port(node)))
+	if err != nil {
+		return nil, err
+	}
+	silent := &SilentListener{listener: listener, mu: new(sync.Mutex)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			silent.mu.Lock()
+			silent.conns = append(silent.conns, conn)
+			silent.mu.Unlock()
+		}
+	}()
+	return silent, nil
+}
+
+// Connections returns the number of connections that have been accepted.
+func (silent *SilentListener) Connections() int {
+	silent.mu.Lock()
+	defer silent.mu.Unlock()
+	return len(silent.conns)
+}
+
+// Close the SilentListener and all of its connections.
+func (silent *SilentListener) Close() {
+	silent.listener.Close()
+	silent.mu.Lock()
+	defer silent.mu.Unlock()
+	for _, conn := range silent.conns {
+		conn.Close()
+	}
+}
+
+// SortNodesByDistance sorts the nodes by their distance to the target, closest
+// first.
+func SortNodesByDistance(nodes []*swarm.Node, target identity.Address) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		closer, err := identity.Closer(nodes[i].Address(), nodes[j].Address(), target)
+		return err == nil && closer
+	})
+}
+
 func GenerateFullTopology(port, numberOfNodes int, delegate swarm.Delegate) ([]*swarm.Node, map[identity.Address][]*swarm.Node, error) {
 	nodes, err := GenerateNodes(port, numberOfNodes, delegate)
 	if err != nil {
@@ -0,0 +1,76 @@
+package swarm
+
+import (
+	"fmt"
+
+	"github.com/republicprotocol/go-identity"
+)
+
+// ValidateDHT checks that every identity.MultiAddress in the dht.DHT appears
+// exactly once, in the bucket that its identity.Address belongs in. It returns
+// an error, wrapping ErrDuplicatePeer or ErrMisplacedPeer, for each
+// identity.MultiAddress that breaks this invariant. When repair is true, each
+// of these identity.MultiAddresses is removed from the dht.DHT and inserted
+// again, so that it ends up in the right bucket once. Errors are returned even
+// when they are repaired. It is useful after restoring the dht.DHT using
+// ImportState.
+func (node *Node) ValidateDHT(repair bool) []error {
+	node.dhtMu.Lock()
+	defer node.dhtMu.Unlock()
+
+	errs := []error{}
+	counts := map[identity.Address]int{}
+	invalid := identity.MultiAddresses{}
+	for _, multiAddress := range node.DHT.MultiAddresses() {
+		address := multiAddress.Address()
+		counts[address]++
+		switch counts[address] {
+		case 1:
+			if !node.inBucketUnsafe(multiAddress) {
+				errs = append(errs, fmt.Errorf("%v: %v", ErrMisplacedPeer, multiAddress))
+				invalid = append(invalid, multiAddress)
+			}
+		case 2:
+			errs = append(errs, fmt.Errorf("%v: %v", ErrDuplicatePeer, multiAddress))
+			invalid = append(invalid, multiAddress)
+		}
+	}
+	if !repair {
+		return errs
+	}
+
+	repaired := map[identity.Address]struct{}{}
+	for _, multiAddress := range invalid {
+		address := multiAddress.Address()
+		if _, ok := repaired[address]; ok {
+			continue
+		}
+		repaired[address] = struct{}{}
+		// A misplaced copy is not in the bucket that the dht.DHT would look
+		// in, so every bucket is purged. The identity.MultiAddress is inserted
+		// again, so a replacement must not be promoted into its bucket.
+		node.purgeMultiAddressUnsafe(multiAddress)
+		if address == node.Address() {
+			continue
+		}
+		if err := node.updateMultiAddressUnsafe(multiAddress); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// inBucketUnsafe returns true if an identity.MultiAddress is in the bucket
+// that its identity.Address belongs in. The dhtMu must be locked.
+func (node *Node) inBucketUnsafe(multiAddress identity.MultiAddress) bool {
+	bucket, err := node.findBucket(multiAddress.Address())
+	if err != nil || bucket == nil {
+		return false
+	}
+	for _, candidate := range bucket.MultiAddresses {
+		if candidate.Address() == multiAddress.Address() {
+			return true
+		}
+	}
+	return false
+}
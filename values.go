@@ -0,0 +1,126 @@
+package swarm
+
+import (
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+)
+
+// A Value is stored by a Node under a key identity.Address. Values expire, so
+// the Node that published a Value must publish it again before it expires.
+type Value struct {
+	Data   []byte
+	Expiry time.Time
+}
+
+// Expired returns true if the Value has expired, otherwise false.
+func (value Value) Expired() bool {
+	return !value.Expiry.IsZero() && time.Now().After(value.Expiry)
+}
+
+// A ValueStore stores the Values held by a Node. Implementations must be safe
+// for concurrent use, and must not return Values that have expired. An
+// in-memory ValueStore is used by default, and a disk-backed ValueStore can be
+// used to keep Values when the Node is restarted.
+type ValueStore interface {
+	// Get returns the Value stored under a key, and true, or false if no
+	// Value is stored under the key.
+	Get(key identity.Address) (Value, bool, error)
+
+	// Put stores a Value under a key, replacing any Value already stored
+	// under the key.
+	Put(key identity.Address, value Value) error
+
+	// Delete removes the Value stored under a key.
+	Delete(key identity.Address) error
+
+	// Iterate calls the function for each Value that is stored, until the
+	// function returns false.
+	Iterate(func(key identity.Address, value Value) bool) error
+}
+
+// memoryValueStore is a ValueStore that keeps Values in memory.
+type memoryValueStore struct {
+	mu     *sync.RWMutex
+	values map[identity.Address]Value
+}
+
+// NewMemoryValueStore returns a ValueStore that keeps Values in memory.
+func NewMemoryValueStore() ValueStore {
+	return &memoryValueStore{
+		mu:     new(sync.RWMutex),
+		values: map[identity.Address]Value{},
+	}
+}
+
+func (store *memoryValueStore) Get(key identity.Address) (Value, bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	value, ok := store.values[key]
+	if !ok || value.Expired() {
+		return Value{}, false, nil
+	}
+	return value, true, nil
+}
+
+func (store *memoryValueStore) Put(key identity.Address, value Value) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.values[key] = value
+	for key, value := range store.values {
+		if value.Expired() {
+			delete(store.values, key)
+		}
+	}
+	return nil
+}
+
+func (store *memoryValueStore) Delete(key identity.Address) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.values, key)
+	return nil
+}
+
+func (store *memoryValueStore) Iterate(f func(key identity.Address, value Value) bool) error {
+	store.mu.RLock()
+	values := make(map[identity.Address]Value, len(store.values))
+	for key, value := range store.values {
+		if !value.Expired() {
+			values[key] = value
+		}
+	}
+	store.mu.RUnlock()
+
+	for key, value := range values {
+		if !f(key, value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// PutValue stores data under a key in the ValueStore of the Node. The data
+// expires after the ttl, or never expires if the ttl is zero.
+func (node *Node) PutValue(key identity.Address, data []byte, ttl time.Duration) error {
+	value := Value{Data: data}
+	if ttl > 0 {
+		value.Expiry = time.Now().Add(ttl)
+	}
+	return node.values.Put(key, value)
+}
+
+// GetValue returns the data stored under a key in the ValueStore of the Node.
+// Returns ErrValueNotFound if no data is stored under the key, or if it has
+// expired.
+func (node *Node) GetValue(key identity.Address) ([]byte, error) {
+	value, ok, err := node.values.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrValueNotFound
+	}
+	return value.Data, nil
+}
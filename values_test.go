@@ -0,0 +1,37 @@
+package swarm_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/republicprotocol/go-swarm-network"
+)
+
+var _ = Describe("Values", func() {
+
+	var node, peer *swarm.Node
+
+	BeforeEach(func() {
+		nodes, err := GenerateNodes(NodePortSwarm, 2, newMockDelegate())
+		Ω(err).ShouldNot(HaveOccurred())
+		node, peer = nodes[0], nodes[1]
+	})
+
+	Context("when storing values", func() {
+
+		It("should return stored values", func() {
+			Ω(node.PutValue(peer.Address(), []byte("value"), 0)).ShouldNot(HaveOccurred())
+			data, err := node.GetValue(peer.Address())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(data).Should(Equal([]byte("value")))
+		})
+
+		It("should not return expired values", func() {
+			Ω(node.PutValue(peer.Address(), []byte("value"), time.Millisecond)).ShouldNot(HaveOccurred())
+			time.Sleep(10 * time.Millisecond)
+			_, err := node.GetValue(peer.Address())
+			Ω(err).Should(Equal(swarm.ErrValueNotFound))
+		})
+	})
+})
@@ -0,0 +1,71 @@
+package swarm
+
+import (
+	"time"
+
+	"github.com/republicprotocol/go-identity"
+	"github.com/republicprotocol/go-rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// VersionMetadataKey and StartedMetadataKey are the gRPC headers that are sent
+// in response to a ping. They contain the Version option of the Node, and the
+// time at which the Node was started, formatted using time.RFC3339Nano.
+const (
+	VersionMetadataKey = "swarm-version"
+	StartedMetadataKey = "swarm-started"
+)
+
+// PeerInfo is the version and start time reported by a peer.
+type PeerInfo struct {
+	Version string
+	Started time.Time
+}
+
+// Uptime returns the time since the peer was started.
+func (info PeerInfo) Uptime() time.Duration {
+	return time.Since(info.Started)
+}
+
+// QueryPeerInfo pings a peer and returns the version and start time that it
+// reports, so that a fleet of Nodes can be inventoried during rolling
+// upgrades. Returns ErrPeerInfoUnavailable if the peer does not report them.
+func (node *Node) QueryPeerInfo(ctx context.Context, target identity.MultiAddress) (PeerInfo, error) {
+	ctx = node.outbound(ctx, MethodPing, target)
+	if err := node.schedule(ctx); err != nil {
+		return PeerInfo{}, err
+	}
+	conn, err := node.dial(ctx, target)
+	if err != nil {
+		return PeerInfo{}, err
+	}
+	defer conn.Close()
+
+	header := metadata.MD{}
+	if _, err := rpc.NewSwarmNodeClient(conn).Ping(ctx, rpc.SerializeMultiAddress(node.MultiAddress()), grpc.Header(&header)); err != nil {
+		return PeerInfo{}, err
+	}
+	if len(header[StartedMetadataKey]) == 0 {
+		return PeerInfo{}, ErrPeerInfoUnavailable
+	}
+	started, err := time.Parse(time.RFC3339Nano, header[StartedMetadataKey][0])
+	if err != nil {
+		return PeerInfo{}, ErrPeerInfoUnavailable
+	}
+	info := PeerInfo{Started: started}
+	if len(header[VersionMetadataKey]) > 0 {
+		info.Version = header[VersionMetadataKey][0]
+	}
+	return info, nil
+}
+
+// versionHeader returns the gRPC header that reports the version and start
+// time of the Node in response to a ping.
+func (node *Node) versionHeader() metadata.MD {
+	node.stateMu.RLock()
+	started := node.started
+	node.stateMu.RUnlock()
+	return metadata.Pairs(VersionMetadataKey, node.options().Version, StartedMetadataKey, started.Format(time.RFC3339Nano))
+}